@@ -0,0 +1,278 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DecxBase/core/types"
+)
+
+const (
+	appleIssuer = "https://appleid.apple.com"
+	// Apple accepts up to six months, a day keeps a leaked secret short lived
+	appleSecretLifetime = 24 * time.Hour
+)
+
+type appleClientSecret struct {
+	mu      sync.Mutex
+	value   string
+	expires time.Time
+}
+
+type appleOAuthProvider struct {
+	*OAuthProviderBase
+	secret *appleClientSecret
+}
+
+func (p appleOAuthProvider) Name() string {
+	return "apple"
+}
+
+// PKCEMethod is empty since Apple doesn't document PKCE support
+func (p appleOAuthProvider) PKCEMethod() string {
+	return ""
+}
+
+func (p appleOAuthProvider) privateKey() (*ecdsa.PrivateKey, error) {
+	var raw []byte
+	switch val := p.config.GetExtra(ConfigPrivateKey).(type) {
+	case string:
+		raw = []byte(val)
+	case []byte:
+		raw = val
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, OAuthErrorInvalidConfig(ConfigPrivateKey)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, OAuthErrorInvalidConfig(ConfigPrivateKey)
+	}
+
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok || key.Curve.Params().BitSize != 256 {
+		return nil, OAuthErrorInvalidConfig(ConfigPrivateKey)
+	}
+
+	return key, nil
+}
+
+// ClientSecret signs the ES256 JWT Apple expects as client_secret, it's reused until shortly before expiry
+func (p appleOAuthProvider) ClientSecret() (string, error) {
+	p.secret.mu.Lock()
+	defer p.secret.mu.Unlock()
+
+	now := time.Now()
+	if len(p.secret.value) > 0 && now.Add(time.Hour).Before(p.secret.expires) {
+		return p.secret.value, nil
+	}
+
+	teamID, ok := configString(p.config, ConfigTeamID)
+	if !ok {
+		return "", OAuthErrorInvalidConfig(ConfigTeamID)
+	}
+
+	keyID, ok := configString(p.config, ConfigKeyID)
+	if !ok {
+		return "", OAuthErrorInvalidConfig(ConfigKeyID)
+	}
+
+	key, err := p.privateKey()
+	if err != nil {
+		return "", err
+	}
+
+	expires := now.Add(appleSecretLifetime)
+	value, err := SignJWT(JWTAlgES256, key, types.JSONDumpData{
+		"kid": keyID,
+	}, types.JSONDumpData{
+		"iss": teamID,
+		"iat": now.Unix(),
+		"exp": expires.Unix(),
+		"aud": appleIssuer,
+		"sub": p.config.ClientID(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	p.secret.value, p.secret.expires = value, expires
+	return value, nil
+}
+
+func (p appleOAuthProvider) Initialize(opts *oAuthOptions, scopes ...string) (*OAuthRequestResult, error) {
+	return p.InitializeContext(context.Background(), opts, scopes...)
+}
+
+func (p appleOAuthProvider) InitializeContext(ctx context.Context, opts *oAuthOptions, scopes ...string) (*OAuthRequestResult, error) {
+	rScopes := ResolveScopes(
+		[]string{},
+		scopes,
+		"name",
+		"email",
+	)
+
+	// requesting name or email requires form_post, so the callback arrives as a cross-site POST
+	uri := p.client.Clone().SetPath("auth/authorize").
+		Set("client_id", p.config.ClientID()).
+		Set("response_type", "code").
+		Set("response_mode", "form_post").
+		SetIf(len(rScopes) > 0, "scope", strings.Join(rScopes, " ")).
+		Set("redirect_uri", opts.Redirect).
+		SetIf(len(opts.State) > 0, "state", opts.State).
+		SetIf(len(opts.Nonce) > 0, "nonce", opts.Nonce)
+
+	return &OAuthRequestResult{
+		Type: OAuthRequestRedirect,
+		Data: uri.String(),
+	}, nil
+}
+
+func (p appleOAuthProvider) tokenRequest(ctx context.Context, mode OAuthRetryMode, cb OAuthRawCallback) (*OAuthToken, error) {
+	secret, err := p.ClientSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := p.RunTokenRequestContext(ctx, func(uri *oAuthURI) *oAuthURI {
+		return cb(uri.SetPath("auth/token")).
+			SetBody("client_id", p.config.ClientID()).
+			SetBody("client_secret", secret)
+	}, RequestOptions(
+		WithReqOptMethod(http.MethodPost),
+		WithReqOptForm(),
+		WithReqOptRetry(mode),
+	), "access_token")
+
+	if err != nil {
+		return nil, err
+	}
+	return p.MakeTokenResult(data)
+}
+
+func (p appleOAuthProvider) Callback(opts *oAuthOptions) (*OAuthToken, error) {
+	return p.CallbackContext(context.Background(), opts)
+}
+
+func (p appleOAuthProvider) CallbackContext(ctx context.Context, opts *oAuthOptions) (*OAuthToken, error) {
+	code, err := opts.GetConfigString("code")
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := p.tokenRequest(ctx, RetryConnectOnly, func(uri *oAuthURI) *oAuthURI {
+		return uri.SetBody("grant_type", "authorization_code").
+			SetBody("code", code).
+			SetBody("redirect_uri", opts.Redirect)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the name only comes along on the very first authorization, keep it with the token
+	if raw, ok := opts.GetConfig("user").(string); ok && len(raw) > 0 {
+		var user types.JSONDumpData
+		if err := json.Unmarshal([]byte(raw), &user); err == nil {
+			if result.Raw == nil {
+				result.Raw = make(map[string]any)
+			}
+			result.Raw["user"] = user
+		}
+	}
+
+	return result, nil
+}
+
+func (p appleOAuthProvider) RefreshToken(token string) (*OAuthToken, error) {
+	return p.RefreshTokenContext(context.Background(), token)
+}
+
+func (p appleOAuthProvider) RefreshTokenContext(ctx context.Context, token string) (*OAuthToken, error) {
+	return p.tokenRequest(ctx, RetrySafe, func(uri *oAuthURI) *oAuthURI {
+		return uri.SetBody("grant_type", "refresh_token").
+			SetBody("refresh_token", token)
+	})
+}
+
+func (p appleOAuthProvider) TokenToUser(token *OAuthToken) (*OAuthUser, error) {
+	return p.TokenToUserContext(context.Background(), token)
+}
+
+func (p appleOAuthProvider) TokenToUserContext(ctx context.Context, token *OAuthToken) (*OAuthUser, error) {
+	claims, err := p.IDTokenClaims(ctx, token.IDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	sub, _ := claims["sub"].(string)
+	if len(sub) < 1 {
+		return nil, OAuthErrorDecodeFailed()
+	}
+
+	profile := make(types.JSONDumpData, len(claims)+1)
+	for key, val := range claims {
+		profile[key] = val
+	}
+
+	if user, ok := token.Extra("user").(map[string]any); ok {
+		profile["name"] = user["name"]
+	}
+
+	email, _ := claims["email"].(string)
+
+	return &OAuthUser{
+		UserID:       sub,
+		IdentityType: "email",
+		Identity:     email,
+		AccessToken:  token.AccessToken,
+		ExpiresIn:    token.ExpiresIn,
+		Profile:      profile,
+	}, nil
+}
+
+func (p appleOAuthProvider) RevokeToken(token string) error {
+	return p.RevokeTokenContext(context.Background(), token)
+}
+
+func (p appleOAuthProvider) RevokeTokenContext(ctx context.Context, token string) error {
+	secret, err := p.ClientSecret()
+	if err != nil {
+		return err
+	}
+
+	_, err = p.CallContext(ctx, func(uri *oAuthURI) *oAuthURI {
+		return uri.SetPath("auth/revoke").
+			SetBody("client_id", p.config.ClientID()).
+			SetBody("client_secret", secret).
+			SetBody("token", token)
+	}, RequestOptions(
+		WithReqOptMethod(http.MethodPost),
+		WithReqOptForm(),
+	))
+
+	return err
+}
+
+// AppleOAuth needs team_id, key_id and the .p8 private_key as config extras, see
+// WithExtraConfig. Mount its callback with WithHandlerSameSite(http.SameSiteNoneMode).
+func AppleOAuth(config OAuthConfig, cbs ...OAuthProviderCallback) *appleOAuthProvider {
+	return &appleOAuthProvider{
+		secret: &appleClientSecret{},
+		OAuthProviderBase: (&OAuthProviderBase{
+			config:  config,
+			client:  URIHost("appleid.apple.com"),
+			keys:    JWKS("https://appleid.apple.com/auth/keys"),
+			issuers: []string{appleIssuer},
+		}).apply(cbs),
+	}
+}
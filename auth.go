@@ -0,0 +1,178 @@
+package oauth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DecxBase/core/types"
+)
+
+type oAuthAuthOptions struct {
+	Scopes []string
+	Claims map[string]string
+	MaxAge time.Duration
+	Realm  string
+}
+
+type OAuthAuthCallback = func(*oAuthAuthOptions)
+
+// WithAuthScopes requires the session's token to carry every one of `scopes`, when the
+// provider doesn't say what it granted the scopes requested at login count
+func WithAuthScopes(scopes ...string) OAuthAuthCallback {
+	return func(o *oAuthAuthOptions) {
+		o.Scopes = append(o.Scopes, scopes...)
+	}
+}
+
+// WithAuthClaim requires an ID token claim to equal `val`, or to contain it for list claims
+func WithAuthClaim(key string, val string) OAuthAuthCallback {
+	return func(o *oAuthAuthOptions) {
+		o.Claims[key] = val
+	}
+}
+
+// WithAuthMaxAge sends users back to the provider once their login is older than `age`
+func WithAuthMaxAge(age time.Duration) OAuthAuthCallback {
+	return func(o *oAuthAuthOptions) {
+		o.MaxAge = age
+	}
+}
+
+func WithAuthRealm(realm string) OAuthAuthCallback {
+	return func(o *oAuthAuthOptions) {
+		o.Realm = realm
+	}
+}
+
+func wantsJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+func claimMatches(claims types.JSONDumpData, key string, val string) bool {
+	switch claim := claims[key].(type) {
+	case nil:
+		return false
+	case []any:
+		for _, item := range claim {
+			if fmt.Sprintf("%v", item) == val {
+				return true
+			}
+		}
+		return false
+	default:
+		return fmt.Sprintf("%v", claim) == val
+	}
+}
+
+// check returns the error a session fails the requirements with and whether logging in again could fix it
+func (o oAuthAuthOptions) check(session *OAuthSession, now time.Time) (string, bool) {
+	if session == nil {
+		return "", true
+	}
+
+	if o.MaxAge > 0 && now.Sub(session.AuthTime) > o.MaxAge {
+		return "invalid_token", true
+	}
+
+	if len(o.Scopes) > 0 && !session.Scope.Has(o.Scopes...) {
+		return "insufficient_scope", true
+	}
+
+	for key, val := range o.Claims {
+		if !claimMatches(session.Claims, key, val) {
+			return "insufficient_claims", false
+		}
+	}
+
+	return "", false
+}
+
+func (o oAuthAuthOptions) challenge(reason string) string {
+	params := []string{fmt.Sprintf("realm=%q", o.Realm)}
+
+	if len(reason) > 0 {
+		params = append(params, fmt.Sprintf("error=%q", reason))
+	}
+
+	if reason == "insufficient_scope" {
+		params = append(params, fmt.Sprintf("scope=%q", strings.Join(o.Scopes, " ")))
+	}
+
+	return "Bearer " + strings.Join(params, ", ")
+}
+
+// RequireAuth protects a route with the session configured through WithHandlerSessions.
+// Browsers are sent through the provider's login and brought back to the original URL,
+// clients asking for JSON get a 401 or 403 with a WWW-Authenticate challenge.
+func (h *oAuthHandler[T]) RequireAuth(name T, cbs ...OAuthAuthCallback) func(http.Handler) http.Handler {
+	// without a session manager nobody can be authenticated, refuse instead of letting requests through
+	if h.opts.Sessions == nil {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			})
+		}
+	}
+
+	opts := &oAuthAuthOptions{
+		Claims: make(map[string]string),
+		Realm:  h.service.providerKey(name),
+	}
+
+	for _, cb := range cbs {
+		cb(opts)
+	}
+
+	key := h.service.providerKey(name)
+	sessions := h.opts.Sessions
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session := SessionFromContext(r.Context())
+			if session == nil {
+				if found, err := sessions.Get(r); err == nil && sessions.Touch(w, r, found) == nil {
+					session = found
+				}
+			}
+
+			// a session from another provider doesn't count
+			if session != nil && session.Provider() != key {
+				session = nil
+			}
+
+			reason, relogin := opts.check(session, sessions.now())
+			if session != nil && len(reason) < 1 {
+				next.ServeHTTP(w, r.WithContext(WithSession(r.Context(), session)))
+				return
+			}
+
+			if wantsJSON(r) || !relogin || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+				status := http.StatusUnauthorized
+				if session != nil && reason != "invalid_token" {
+					status = http.StatusForbidden
+				}
+
+				w.Header().Set("WWW-Authenticate", opts.challenge(reason))
+				http.Error(w, http.StatusText(status), status)
+				return
+			}
+
+			scopes := append(append(make([]string, 0), h.opts.Scopes[key]...), opts.Scopes...)
+			extra := []OAuthOptionCallback{
+				WithOptReturnTo(r.URL.RequestURI()),
+			}
+
+			if reason == "invalid_token" {
+				extra = append(extra, WithOptPrompt("login"))
+			}
+
+			if opts.MaxAge > 0 {
+				extra = append(extra, WithOptMaxAge(opts.MaxAge))
+			}
+
+			h.begin(w, r, name, key, scopes, extra...)
+		})
+	}
+}
@@ -2,24 +2,69 @@ package oauth
 
 import (
 	"bytes"
-	"encoding/base64"
+	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/DecxBase/core/types"
 	"github.com/DecxBase/core/utils"
 )
 
+type OAuthProviderCallback = func(*OAuthProviderBase)
+
 type OAuthProviderBase struct {
-	client *oAuthURI
-	config OAuthConfig
+	client     *oAuthURI
+	httpClient *http.Client
+	retry      *OAuthRetryPolicy
+	config     OAuthConfig
+	keys       *oAuthKeySet
+	issuers    []string
+}
+
+func WithProviderHTTPClient(client *http.Client) OAuthProviderCallback {
+	return func(p *OAuthProviderBase) {
+		p.httpClient = client
+	}
+}
+
+func WithProviderTransport(transport http.RoundTripper) OAuthProviderCallback {
+	return WithProviderHTTPClient(&http.Client{Transport: transport})
+}
+
+func WithProviderRetry(policy *OAuthRetryPolicy) OAuthProviderCallback {
+	return func(p *OAuthProviderBase) {
+		p.retry = policy
+	}
+}
+
+func (p *OAuthProviderBase) apply(cbs []OAuthProviderCallback) *OAuthProviderBase {
+	for _, cb := range cbs {
+		cb(p)
+	}
+
+	return p
+}
+
+func (p OAuthProviderBase) withClient(ctx context.Context) context.Context {
+	if p.httpClient != nil {
+		return WithHTTPClient(ctx, p.httpClient)
+	}
+
+	return ctx
 }
 
 func (p OAuthProviderBase) Name() string {
 	return "unknown"
 }
 
+func (p OAuthProviderBase) PKCEMethod() string {
+	return PKCEMethodS256
+}
+
 func (p OAuthProviderBase) FieldMappings() utils.DataMap[string] {
 	return utils.MakeDataMap(map[string]string{})
 }
@@ -60,49 +105,137 @@ func (p OAuthProviderBase) Validate(data types.JSONStringData) error {
 	return nil
 }
 
+func (p OAuthProviderBase) AuthorizeRequest(req *http.Request, token *OAuthToken) error {
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token.AccessToken))
+	return nil
+}
+
 func (p OAuthProviderBase) Initialize(opts *oAuthOptions, scopes ...string) (*OAuthRequestResult, error) {
+	return p.InitializeContext(context.Background(), opts, scopes...)
+}
+
+func (p OAuthProviderBase) InitializeContext(ctx context.Context, opts *oAuthOptions, scopes ...string) (*OAuthRequestResult, error) {
 	return nil, OAuthErrorUnimplemented(p.Name(), "Initialize")
 }
 
 func (p OAuthProviderBase) Callback(opts *oAuthOptions) (*OAuthToken, error) {
+	return p.CallbackContext(context.Background(), opts)
+}
+
+func (p OAuthProviderBase) CallbackContext(ctx context.Context, opts *oAuthOptions) (*OAuthToken, error) {
 	return nil, OAuthErrorUnimplemented(p.Name(), "Callback")
 }
 
 func (p OAuthProviderBase) RefreshToken(token string) (*OAuthToken, error) {
+	return p.RefreshTokenContext(context.Background(), token)
+}
+
+func (p OAuthProviderBase) RefreshTokenContext(ctx context.Context, token string) (*OAuthToken, error) {
 	return nil, OAuthErrorUnimplemented(p.Name(), "RefreshToken")
 }
 
 func (p OAuthProviderBase) TokenToUser(token *OAuthToken) (*OAuthUser, error) {
+	return p.TokenToUserContext(context.Background(), token)
+}
+
+func (p OAuthProviderBase) TokenToUserContext(ctx context.Context, token *OAuthToken) (*OAuthUser, error) {
 	return nil, OAuthErrorUnimplemented(p.Name(), "TokenToUser")
 }
 
+func (p OAuthProviderBase) RevokeToken(token string) error {
+	return p.RevokeTokenContext(context.Background(), token)
+}
+
+func (p OAuthProviderBase) RevokeTokenContext(ctx context.Context, token string) error {
+	return OAuthErrorUnimplemented(p.Name(), "RevokeToken")
+}
+
 func (p OAuthProviderBase) Get(token string, fields []string, opts *oAuthOptions) (types.JSONDumpData, error) {
+	return p.GetContext(context.Background(), token, fields, opts)
+}
+
+func (p OAuthProviderBase) GetContext(ctx context.Context, token string, fields []string, opts *oAuthOptions) (types.JSONDumpData, error) {
 	return nil, OAuthErrorUnimplemented(p.Name(), "Get")
 }
 
 func (p OAuthProviderBase) Call(cb OAuthRawCallback, opts *oAuthRequestOptions) (types.JSONDumpData, error) {
+	return p.CallContext(context.Background(), cb, opts)
+}
+
+func (p OAuthProviderBase) CallContext(ctx context.Context, cb OAuthRawCallback, opts *oAuthRequestOptions) (types.JSONDumpData, error) {
 	uri := cb(p.client.Clone())
 	if utils.CheckContains([]string{
 		"POST",
 		"PATCH",
 		"PUT",
+		"DELETE",
 	}, opts.Method) && len(uri.Body) > 0 {
-		WithReqOptBody(bytes.NewBuffer(uri.GetPayload()))(opts)
+		if opts.Headers["Content-Type"] == "application/x-www-form-urlencoded" {
+			WithReqOptBody(bytes.NewBuffer(uri.GetFormPayload()))(opts)
+		} else {
+			WithReqOptBody(bytes.NewBuffer(uri.GetPayload()))(opts)
+		}
+	}
+
+	if opts.RetryPolicy == nil {
+		opts.RetryPolicy = p.retry
+		if opts.RetryPolicy == nil {
+			opts.RetryPolicy = DefaultRetryPolicy()
+		}
 	}
 
-	res, err := OAuthRequest(uri, opts)
+	res, err := OAuthDoRequestContext(p.withClient(ctx), uri, opts)
 	if err != nil {
 		return nil, err
 	}
 
+	return DecodeResponse(res)
+}
+
+// DecodeResponse reads JSON objects, wraps JSON arrays as `{"data": [...]}` and falls
+// back to form encoding, which some token endpoints still answer with
+func DecodeResponse(res *OAuthResponse) (types.JSONDumpData, error) {
+	body := bytes.TrimSpace(res.Body)
+	if len(body) < 1 {
+		return make(types.JSONDumpData), nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType == "application/x-www-form-urlencoded" || (mediaType == "text/plain" && body[0] != '{' && body[0] != '[') {
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, OAuthErrorDecodeFailed()
+		}
+
+		data := make(types.JSONDumpData, len(values))
+		for key := range values {
+			data[key] = values.Get(key)
+		}
+
+		return data, nil
+	}
+
+	if body[0] == '[' {
+		var list []any
+		if err := json.Unmarshal(body, &list); err != nil {
+			return nil, err
+		}
+
+		return types.JSONDumpData{"data": list}, nil
+	}
+
 	var data types.JSONDumpData
-	err = json.Unmarshal(res, &data)
+	err := json.Unmarshal(body, &data)
 
 	return data, err
 }
 
 func (p OAuthProviderBase) RunTokenRequest(cb OAuthRawCallback, opts *oAuthRequestOptions, key string) (types.JSONDumpData, error) {
-	data, err := p.Call(cb, opts)
+	return p.RunTokenRequestContext(context.Background(), cb, opts, key)
+}
+
+func (p OAuthProviderBase) RunTokenRequestContext(ctx context.Context, cb OAuthRawCallback, opts *oAuthRequestOptions, key string) (types.JSONDumpData, error) {
+	data, err := p.CallContext(ctx, cb, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -140,21 +273,83 @@ func (p OAuthProviderBase) MakeTokenResult(data types.JSONDumpData) (*OAuthToken
 }
 
 func (p OAuthProviderBase) DecodeIDToken(token string) (types.JSONDumpData, error) {
-	segments := strings.Split(token, ".")
-	if len(segments) != 3 {
-		return nil, OAuthErrorJWTFailed()
+	parsed, err := ParseJWT(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsed.Claims, nil
+}
+
+func (p OAuthProviderBase) IDTokenValidator() IDTokenValidator {
+	validator := IDTokenValidator{
+		Issuers:   p.issuers,
+		Audience:  p.config.ClientID(),
+		ClockSkew: DefaultClockSkew,
+	}
+
+	if skew, ok := p.config.GetExtra(ConfigClockSkew).(time.Duration); ok {
+		validator.ClockSkew = skew
+	}
+
+	if now, ok := p.config.GetExtra(ConfigClock).(func() time.Time); ok {
+		validator.Now = now
+	}
+
+	return validator
+}
+
+func (p OAuthProviderBase) VerifyIDTokenSignature(ctx context.Context, parsed *OAuthJWT) error {
+	if skip, _ := p.config.GetExtra(ConfigInsecureSkipVerify).(bool); skip {
+		return nil
+	}
+
+	if p.keys == nil {
+		return OAuthErrorIDTokenSignature()
+	}
+
+	return p.keys.Verify(p.withClient(ctx), parsed)
+}
+
+func (p OAuthProviderBase) VerifyIDToken(token string, nonce string) (types.JSONDumpData, error) {
+	return p.VerifyIDTokenContext(context.Background(), token, nonce)
+}
+
+func (p OAuthProviderBase) VerifyIDTokenContext(ctx context.Context, token string, nonce string) (types.JSONDumpData, error) {
+	return p.verifyIDToken(ctx, token, nonce, p.IDTokenValidator())
+}
+
+// IDTokenClaims reads the claims of a token which was verified on callback. Signature,
+// issuer and audience are checked again, `exp` isn't since ID tokens expire long before
+// the access token they came with.
+func (p OAuthProviderBase) IDTokenClaims(ctx context.Context, token string) (types.JSONDumpData, error) {
+	validator := p.IDTokenValidator()
+	validator.SkipExpiry = true
+
+	return p.verifyIDToken(ctx, token, "", validator)
+}
+
+func (p OAuthProviderBase) verifyIDToken(ctx context.Context, token string, nonce string, validator IDTokenValidator) (types.JSONDumpData, error) {
+	parsed, err := ParseJWT(token)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.VerifyIDTokenSignature(ctx, parsed)
+	if err != nil {
+		return nil, err
 	}
 
-	payloadData, err := p.Base64Decode(segments[1])
+	err = validator.Validate(parsed.Claims, nonce)
 	if err != nil {
 		return nil, err
 	}
 
-	return payloadData, err
+	return parsed.Claims, nil
 }
 
 func (p OAuthProviderBase) Base64Decode(str string) (types.JSONDumpData, error) {
-	dataBytes, err := base64.RawStdEncoding.DecodeString(str)
+	dataBytes, err := Base64URLDecode(str)
 	if err != nil {
 		return nil, OAuthErrorJWTFailed()
 	}
@@ -0,0 +1,134 @@
+package oauth
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/DecxBase/core/types"
+	"github.com/gorilla/schema"
+)
+
+// MaxCallbackSize bounds form_post bodies, they only ever carry a handful of short fields
+const MaxCallbackSize = 64 << 10
+
+type OAuthCallbackData struct {
+	Code             string `schema:"code"`
+	State            string `schema:"state"`
+	Error            string `schema:"error"`
+	ErrorDescription string `schema:"error_description"`
+	ErrorURI         string `schema:"error_uri"`
+	ErrorCode        string `schema:"error_code"`
+	ErrorReason      string `schema:"error_reason"`
+	IDToken          string `schema:"id_token"`
+	Issuer           string `schema:"iss"`
+	User             string `schema:"user"`
+	// OAuth 1.0a answers with the request token and a verifier instead of a code
+	OAuthToken    string `schema:"oauth_token"`
+	OAuthVerifier string `schema:"oauth_verifier"`
+	Denied        string `schema:"denied"`
+	// Fragment carries `location.hash` posted back by a page for providers answering in the fragment
+	Fragment string `schema:"fragment"`
+}
+
+func (d OAuthCallbackData) Validate() error {
+	if len(d.Error) > 0 || len(d.Denied) > 0 || len(d.OAuthVerifier) > 0 {
+		return nil
+	}
+
+	if len(d.Code) < 1 {
+		return OAuthErrorMissingParam("code")
+	}
+
+	return nil
+}
+
+func (d OAuthCallbackData) Data() types.JSONStringData {
+	data := make(types.JSONStringData)
+
+	for key, val := range map[string]string{
+		"code":              d.Code,
+		"state":             d.State,
+		"error":             d.Error,
+		"error_description": d.ErrorDescription,
+		"error_uri":         d.ErrorURI,
+		"error_code":        d.ErrorCode,
+		"error_reason":      d.ErrorReason,
+		"id_token":          d.IDToken,
+		"iss":               d.Issuer,
+		"user":              d.User,
+		"oauth_token":       d.OAuthToken,
+		"oauth_verifier":    d.OAuthVerifier,
+		"denied":            d.Denied,
+	} {
+		if len(val) > 0 {
+			data[key] = val
+		}
+	}
+
+	return data
+}
+
+var callbackDecoder = func() *schema.Decoder {
+	decoder := schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(true)
+
+	return decoder
+}()
+
+// ParseCallbackRequest reads the query, a response_mode=form_post body and a forwarded fragment
+func ParseCallbackRequest(r *http.Request) (*OAuthCallbackData, error) {
+	if r.Method == http.MethodPost && r.Body != nil {
+		r.Body = http.MaxBytesReader(nil, r.Body, MaxCallbackSize)
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return nil, OAuthErrorDecodeFailed()
+	}
+
+	values := make(url.Values)
+	for key, val := range r.Form {
+		values[key] = val[:1]
+	}
+
+	if fragment := values.Get("fragment"); len(fragment) > 0 {
+		extra, err := url.ParseQuery(fragment)
+		if err != nil {
+			return nil, OAuthErrorDecodeFailed()
+		}
+
+		for key, val := range extra {
+			if !values.Has(key) && len(val) > 0 {
+				values[key] = val[:1]
+			}
+		}
+	}
+
+	data := new(OAuthCallbackData)
+	if err := callbackDecoder.Decode(data, values); err != nil {
+		return nil, OAuthErrorDecodeFailed()
+	}
+
+	if err := data.Validate(); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s OAuthService[T]) CallbackFromRequest(name T, r *http.Request, cbs ...OAuthOptionCallback) (*OAuthToken, error) {
+	result, _, err := s.CallbackStateFromRequest(name, r, cbs...)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s OAuthService[T]) CallbackStateFromRequest(name T, r *http.Request, cbs ...OAuthOptionCallback) (*OAuthToken, *OAuthState, error) {
+	data, err := ParseCallbackRequest(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.CallbackStateContext(r.Context(), name, data.Data(), cbs...)
+}
@@ -1,5 +1,19 @@
 package oauth
 
+import "time"
+
+const (
+	ConfigInsecureSkipVerify = "insecure_skip_verify"
+	ConfigClockSkew          = "clock_skew"
+	ConfigClock              = "clock"
+	ConfigBaseURL            = "base_url"
+	ConfigTenants            = "tenants"
+	ConfigTeamID             = "team_id"
+	ConfigKeyID              = "key_id"
+	ConfigPrivateKey         = "private_key"
+	ConfigSignatureMethod    = "signature_method"
+)
+
 type oAuthConfig struct {
 	clientID     string
 	clientSecret string
@@ -39,3 +53,35 @@ func WithExtraConfig(key string, val any) OAuthConfigCallback {
 		o.extras[key] = val
 	}
 }
+
+// WithInsecureSkipVerify disables ID token signature checks, only meant for tests
+func WithInsecureSkipVerify() OAuthConfigCallback {
+	return WithExtraConfig(ConfigInsecureSkipVerify, true)
+}
+
+func WithClockSkew(skew time.Duration) OAuthConfigCallback {
+	return WithExtraConfig(ConfigClockSkew, skew)
+}
+
+func WithClock(now func() time.Time) OAuthConfigCallback {
+	return WithExtraConfig(ConfigClock, now)
+}
+
+// WithBaseURL points a provider at a self-hosted instance, e.g. GitHub Enterprise Server
+func WithBaseURL(base string) OAuthConfigCallback {
+	return WithExtraConfig(ConfigBaseURL, base)
+}
+
+// WithTenants restricts multi-tenant providers to the listed tenant IDs
+func WithTenants(tenants ...string) OAuthConfigCallback {
+	return WithExtraConfig(ConfigTenants, tenants)
+}
+
+func configString(config OAuthConfig, key string) (string, bool) {
+	if config == nil {
+		return "", false
+	}
+
+	val, ok := config.GetExtra(key).(string)
+	return val, ok && len(val) > 0
+}
@@ -0,0 +1,261 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/DecxBase/core/types"
+	"github.com/DecxBase/core/utils"
+)
+
+const discordAPI = "api/v10"
+
+type discordOAuthProvider struct {
+	*OAuthProviderBase
+}
+
+func (p discordOAuthProvider) Name() string {
+	return "discord"
+}
+
+func (p discordOAuthProvider) FieldMappings() utils.DataMap[string] {
+	return utils.MakeDataMap(map[string]string{
+		"full_name": "global_name",
+		"avatar":    "avatar",
+	})
+}
+
+func (p discordOAuthProvider) Initialize(opts *oAuthOptions, scopes ...string) (*OAuthRequestResult, error) {
+	return p.InitializeContext(context.Background(), opts, scopes...)
+}
+
+// InitializeContext switches to the bot install flow when `permissions` is set through
+// WithOptDiscordBot, adding the `bot` scope and passing along `permissions` and `guild_id`
+func (p discordOAuthProvider) InitializeContext(ctx context.Context, opts *oAuthOptions, scopes ...string) (*OAuthRequestResult, error) {
+	permissions, _ := opts.GetConfig("permissions").(string)
+	guildID, _ := opts.GetConfig("guild_id").(string)
+
+	rScopes := ResolveScopes(
+		[]string{
+			"identify",
+			"email",
+		},
+		scopes,
+		"guilds",
+	)
+	if len(permissions) > 0 && !utils.CheckContains(rScopes, "bot") {
+		rScopes = append(rScopes, "bot")
+	}
+
+	uri := p.client.Clone().Join("oauth2/authorize").
+		Set("client_id", p.config.ClientID()).
+		Set("response_type", "code").
+		Set("scope", strings.Join(rScopes, " ")).
+		Set("redirect_uri", opts.Redirect).
+		SetIf(len(opts.State) > 0, "state", opts.State).
+		SetIf(len(opts.Prompt) > 0, "prompt", opts.Prompt).
+		SetIf(len(permissions) > 0, "permissions", permissions).
+		SetIf(len(guildID) > 0, "guild_id", guildID).
+		SetIf(len(opts.CodeChallenge) > 0, "code_challenge", opts.CodeChallenge).
+		SetIf(len(opts.CodeChallenge) > 0, "code_challenge_method", opts.CodeChallengeMethod)
+
+	return &OAuthRequestResult{
+		Type: OAuthRequestRedirect,
+		Data: uri.String(),
+	}, nil
+}
+
+func (p discordOAuthProvider) tokenRequest(ctx context.Context, cb OAuthRawCallback) (*OAuthToken, error) {
+	data, err := p.RunTokenRequestContext(ctx, func(uri *oAuthURI) *oAuthURI {
+		return cb(uri.Join(discordAPI).Join("oauth2/token")).
+			SetBody("client_id", p.config.ClientID()).
+			SetBody("client_secret", p.config.ClientSecret())
+	}, RequestOptions(
+		WithReqOptMethod(http.MethodPost),
+		WithReqOptForm(),
+		WithReqOptRetry(RetryConnectOnly),
+	), "access_token")
+
+	if err != nil {
+		return nil, err
+	}
+	return p.MakeTokenResult(data)
+}
+
+func (p discordOAuthProvider) Callback(opts *oAuthOptions) (*OAuthToken, error) {
+	return p.CallbackContext(context.Background(), opts)
+}
+
+func (p discordOAuthProvider) CallbackContext(ctx context.Context, opts *oAuthOptions) (*OAuthToken, error) {
+	code, err := opts.GetConfigString("code")
+	if err != nil {
+		return nil, err
+	}
+
+	return p.tokenRequest(ctx, func(uri *oAuthURI) *oAuthURI {
+		return uri.SetBody("grant_type", "authorization_code").
+			SetBody("code", code).
+			SetBody("redirect_uri", opts.Redirect).
+			SetBodyIf(len(opts.CodeVerifier) > 0, "code_verifier", opts.CodeVerifier)
+	})
+}
+
+func (p discordOAuthProvider) RefreshToken(token string) (*OAuthToken, error) {
+	return p.RefreshTokenContext(context.Background(), token)
+}
+
+func (p discordOAuthProvider) RefreshTokenContext(ctx context.Context, token string) (*OAuthToken, error) {
+	return p.tokenRequest(ctx, func(uri *oAuthURI) *oAuthURI {
+		return uri.SetBody("grant_type", "refresh_token").
+			SetBody("refresh_token", token)
+	})
+}
+
+func (p discordOAuthProvider) TokenToUser(token *OAuthToken) (*OAuthUser, error) {
+	return p.TokenToUserContext(context.Background(), token)
+}
+
+func (p discordOAuthProvider) TokenToUserContext(ctx context.Context, token *OAuthToken) (*OAuthUser, error) {
+	profile, err := p.GetContext(ctx, token.AccessToken, nil, Options())
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := profile["id"].(string)
+	if !ok || len(id) < 1 {
+		return nil, OAuthErrorDecodeFailed()
+	}
+
+	user := &OAuthUser{
+		UserID:       id,
+		IdentityType: "username",
+		AccessToken:  token.AccessToken,
+		ExpiresIn:    token.ExpiresIn,
+		Profile:      profile,
+	}
+	user.Identity, _ = profile["username"].(string)
+
+	// unverified addresses can be claimed by anyone, so they never become the identity
+	if verified, _ := profile["verified"].(bool); verified {
+		if email, _ := profile["email"].(string); len(email) > 0 {
+			user.IdentityType = "email"
+			user.Identity = email
+		}
+	}
+
+	return user, nil
+}
+
+func (p discordOAuthProvider) RevokeToken(token string) error {
+	return p.RevokeTokenContext(context.Background(), token)
+}
+
+func (p discordOAuthProvider) RevokeTokenContext(ctx context.Context, token string) error {
+	_, err := p.CallContext(ctx, func(uri *oAuthURI) *oAuthURI {
+		return uri.Join(discordAPI).Join("oauth2/token/revoke").
+			SetBody("client_id", p.config.ClientID()).
+			SetBody("client_secret", p.config.ClientSecret()).
+			SetBody("token", token)
+	}, RequestOptions(
+		WithReqOptMethod(http.MethodPost),
+		WithReqOptForm(),
+	))
+
+	return err
+}
+
+func (p discordOAuthProvider) Get(token string, fields []string, opts *oAuthOptions) (types.JSONDumpData, error) {
+	return p.GetContext(context.Background(), token, fields, opts)
+}
+
+func (p discordOAuthProvider) GetContext(ctx context.Context, token string, fields []string, opts *oAuthOptions) (types.JSONDumpData, error) {
+	path := "users/@me"
+	if len(opts.RequestPath) > 0 {
+		path = opts.RequestPath
+	}
+
+	data, err := p.CallContext(ctx, func(uri *oAuthURI) *oAuthURI {
+		return uri.Join(discordAPI).Join(path)
+	}, RequestOptions(
+		WithReqHeader("Authorization", fmt.Sprintf("Bearer %s", token)),
+	))
+	if err != nil || len(fields) < 1 {
+		return data, err
+	}
+
+	result := make(types.JSONDumpData, len(fields))
+	for _, field := range fields {
+		if val, ok := data[field]; ok {
+			result[field] = val
+		}
+	}
+
+	return result, nil
+}
+
+// Guilds lists the guilds of the token owner, requires the `guilds` scope
+func (p discordOAuthProvider) Guilds(token string) ([]types.JSONDumpData, error) {
+	return p.GuildsContext(context.Background(), token)
+}
+
+func (p discordOAuthProvider) GuildsContext(ctx context.Context, token string) ([]types.JSONDumpData, error) {
+	data, err := p.GetContext(ctx, token, nil, Options(
+		WithOptRequestPath("users/@me/guilds"),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	items, _ := data["data"].([]any)
+	guilds := make([]types.JSONDumpData, 0, len(items))
+	for _, item := range items {
+		if guild, ok := item.(map[string]any); ok {
+			guilds = append(guilds, guild)
+		}
+	}
+
+	return guilds, nil
+}
+
+// InGuild reports whether the token owner is a member of `guildID`
+func (p discordOAuthProvider) InGuild(token string, guildID string) (bool, error) {
+	return p.InGuildContext(context.Background(), token, guildID)
+}
+
+func (p discordOAuthProvider) InGuildContext(ctx context.Context, token string, guildID string) (bool, error) {
+	guilds, err := p.GuildsContext(ctx, token)
+	if err != nil {
+		return false, err
+	}
+
+	for _, guild := range guilds {
+		if id, _ := guild["id"].(string); id == guildID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// WithOptDiscordBot requests the bot install flow with the given permission bits,
+// `guildID` may be empty to let the user pick the guild
+func WithOptDiscordBot(permissions int64, guildID string) OAuthOptionCallback {
+	return func(o *oAuthOptions) {
+		o.Config["permissions"] = strconv.FormatInt(permissions, 10)
+		if len(guildID) > 0 {
+			o.Config["guild_id"] = guildID
+		}
+	}
+}
+
+func DiscordOAuth(config OAuthConfig, cbs ...OAuthProviderCallback) *discordOAuthProvider {
+	return &discordOAuthProvider{
+		OAuthProviderBase: (&OAuthProviderBase{
+			config: config,
+			client: URIHost("discord.com"),
+		}).apply(cbs),
+	}
+}
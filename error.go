@@ -1,14 +1,34 @@
 package oauth
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
+	"unicode/utf8"
 )
 
+const MaxErrorBodySize = 1024
+
+var OAuthErrorHeaders = []string{
+	"Content-Type",
+	"Retry-After",
+	"WWW-Authenticate",
+	"X-Request-Id",
+	"X-Fb-Trace-Id",
+	"X-Github-Request-Id",
+}
+
 type OAuthError struct {
-	Reason  string
-	Code    string
-	Message string
+	Reason   string
+	Code     string
+	Message  string
+	URI      string
+	Provider string
+	Status   int
+	Headers  http.Header
+	Body     string
 }
 
 func (e OAuthError) Error() string {
@@ -22,10 +42,18 @@ func (e OAuthError) Error() string {
 		msgs = append(msgs, fmt.Sprintf("[%s]", e.Reason))
 	}
 
+	if len(e.Provider) > 0 {
+		msgs = append([]string{fmt.Sprintf("%s:", e.Provider)}, msgs...)
+	}
+
 	if len(e.Message) > 0 {
 		msgs = append(msgs, e.Message)
 	}
 
+	if e.Status > 0 {
+		msgs = append(msgs, fmt.Sprintf("(HTTP %d)", e.Status))
+	}
+
 	if len(msgs) > 0 {
 		return strings.Join(msgs, " ")
 	}
@@ -33,6 +61,92 @@ func (e OAuthError) Error() string {
 	return "Unknown oauth error"
 }
 
+func OAuthErrorReason(err error) string {
+	var oauthErr OAuthError
+	if errors.As(err, &oauthErr) {
+		return oauthErr.Reason
+	}
+
+	return ""
+}
+
+func OAuthErrorStatus(err error) int {
+	var oauthErr OAuthError
+	if errors.As(err, &oauthErr) {
+		return oauthErr.Status
+	}
+
+	return 0
+}
+
+func withErrorProvider(err error, name string) error {
+	var oauthErr OAuthError
+	if errors.As(err, &oauthErr) && len(oauthErr.Provider) < 1 {
+		oauthErr.Provider = name
+		return oauthErr
+	}
+
+	return err
+}
+
+func truncateBody(body []byte) string {
+	if len(body) <= MaxErrorBodySize {
+		return string(body)
+	}
+
+	cut := MaxErrorBodySize
+	for cut > 0 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+
+	return string(body[:cut]) + "..."
+}
+
+func OAuthErrorResponse(res *OAuthResponse) OAuthError {
+	result := OAuthError{
+		Reason:  "http_status",
+		Message: http.StatusText(res.StatusCode),
+		Status:  res.StatusCode,
+		Headers: make(http.Header),
+		Body:    truncateBody(res.Body),
+	}
+
+	for _, key := range OAuthErrorHeaders {
+		if val := res.Header.Values(key); len(val) > 0 {
+			result.Headers[key] = val
+		}
+	}
+
+	var data map[string]any
+	if json.Unmarshal(res.Body, &data) != nil {
+		return result
+	}
+
+	switch info := data["error"].(type) {
+	case string:
+		// RFC 6749 section 5.2 error response
+		result.Reason = info
+		result.Message, _ = data["error_description"].(string)
+		result.URI, _ = data["error_uri"].(string)
+	case map[string]any:
+		// graph style nested errors
+		if reason, ok := info["type"].(string); ok {
+			result.Reason = reason
+		}
+		if message, ok := info["message"].(string); ok {
+			result.Message = message
+		}
+		if code, ok := info["code"].(float64); ok {
+			result.Code = fmt.Sprintf("%d", int64(code))
+		}
+		if subcode, ok := info["error_subcode"].(float64); ok {
+			result.Code = fmt.Sprintf("%s.%d", result.Code, int64(subcode))
+		}
+	}
+
+	return result
+}
+
 func OAuthErrorToken() OAuthError {
 	return OAuthError{
 		Reason:  "invalid_token",
@@ -40,6 +154,20 @@ func OAuthErrorToken() OAuthError {
 	}
 }
 
+func OAuthErrorTokenExpired() OAuthError {
+	return OAuthError{
+		Reason:  "token_expired",
+		Message: "Access token has expired and can't be refreshed",
+	}
+}
+
+func OAuthErrorTokenNotFound() OAuthError {
+	return OAuthError{
+		Reason:  "token_not_found",
+		Message: "No stored token matches the given key",
+	}
+}
+
 func OAuthErrorAccessDenied() OAuthError {
 	return OAuthError{
 		Reason:  "access_denied",
@@ -61,9 +189,143 @@ func OAuthErrorDecodeFailed() OAuthError {
 	}
 }
 
+func OAuthErrorMissingParam(name string) OAuthError {
+	return OAuthError{
+		Reason:  "invalid_request",
+		Code:    name,
+		Message: fmt.Sprintf("Missing required parameter [%s]", name),
+	}
+}
+
+func OAuthErrorRedirectNotAllowed(target string) OAuthError {
+	return OAuthError{
+		Reason:  "invalid_redirect",
+		Message: fmt.Sprintf("Redirect target [%s] isn't allowed", target),
+	}
+}
+
+func OAuthErrorInvalidConfig(key string) OAuthError {
+	return OAuthError{
+		Reason:  "invalid_config",
+		Message: fmt.Sprintf("Config [%s] is missing or invalid", key),
+	}
+}
+
 func OAuthErrorUnimplemented(name string, method string) OAuthError {
 	return OAuthError{
 		Reason:  "unimplemented",
 		Message: fmt.Sprintf("Service [%s] hasn't implmented method [%s]", name, method),
 	}
 }
+
+func OAuthErrorStateMismatch() OAuthError {
+	return OAuthError{
+		Reason:  "invalid_state",
+		Code:    "mismatch",
+		Message: "State parameter is missing or doesn't match",
+	}
+}
+
+func OAuthErrorStateReplayed() OAuthError {
+	return OAuthError{
+		Reason:  "invalid_state",
+		Code:    "replayed",
+		Message: "State parameter has already been used",
+	}
+}
+
+func OAuthErrorStateExpired() OAuthError {
+	return OAuthError{
+		Reason:  "invalid_state",
+		Code:    "expired",
+		Message: "State parameter has expired",
+	}
+}
+
+func OAuthErrorSessionMissing() OAuthError {
+	return OAuthError{
+		Reason:  "invalid_session",
+		Code:    "missing",
+		Message: "No valid session found",
+	}
+}
+
+func OAuthErrorSessionExpired() OAuthError {
+	return OAuthError{
+		Reason:  "invalid_session",
+		Code:    "expired",
+		Message: "Session has expired",
+	}
+}
+
+func OAuthErrorUnknownKey(kid string) OAuthError {
+	return OAuthError{
+		Reason:  "encryption",
+		Code:    "unknown_key",
+		Message: fmt.Sprintf("No key with id [%s] in the keyring", kid),
+	}
+}
+
+func OAuthErrorDecryptFailed() OAuthError {
+	return OAuthError{
+		Reason:  "encryption",
+		Code:    "decrypt",
+		Message: "Failed to decrypt stored token",
+	}
+}
+
+func OAuthErrorIDTokenSignature() OAuthError {
+	return OAuthError{
+		Reason:  "id_token_signature",
+		Message: "Failed to verify ID token signature",
+	}
+}
+
+func OAuthErrorIDTokenIssuer() OAuthError {
+	return OAuthError{
+		Reason:  "id_token_issuer",
+		Message: "ID token was issued by an unexpected issuer",
+	}
+}
+
+func OAuthErrorIDTokenAudience() OAuthError {
+	return OAuthError{
+		Reason:  "id_token_audience",
+		Message: "ID token wasn't issued for this client",
+	}
+}
+
+func OAuthErrorIDTokenAuthorizedParty() OAuthError {
+	return OAuthError{
+		Reason:  "id_token_azp",
+		Message: "ID token authorized party doesn't match this client",
+	}
+}
+
+func OAuthErrorIDTokenExpired() OAuthError {
+	return OAuthError{
+		Reason:  "id_token_expired",
+		Message: "ID token has expired",
+	}
+}
+
+func OAuthErrorIDTokenNotYetValid() OAuthError {
+	return OAuthError{
+		Reason:  "id_token_not_yet_valid",
+		Message: "ID token isn't valid yet",
+	}
+}
+
+func OAuthErrorIDTokenIssuedAt() OAuthError {
+	return OAuthError{
+		Reason:  "id_token_issued_at",
+		Message: "ID token issue time is missing or in the future",
+	}
+}
+
+func OAuthErrorIDTokenNonce() OAuthError {
+	return OAuthError{
+		Reason:  "id_token_nonce",
+		Message: "ID token nonce doesn't match",
+	}
+}
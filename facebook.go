@@ -1,7 +1,12 @@
 package oauth
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"net/http"
 	"strings"
 
 	"github.com/DecxBase/core/types"
@@ -23,7 +28,27 @@ func (p facebookOAuthProvider) FieldMappings() utils.DataMap[string] {
 	})
 }
 
+func (p facebookOAuthProvider) AppSecretProof(token string) string {
+	mac := hmac.New(sha256.New, []byte(p.config.ClientSecret()))
+	mac.Write([]byte(token))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (p facebookOAuthProvider) AuthorizeRequest(req *http.Request, token *OAuthToken) error {
+	query := req.URL.Query()
+	query.Set("access_token", token.AccessToken)
+	query.Set("appsecret_proof", p.AppSecretProof(token.AccessToken))
+	req.URL.RawQuery = query.Encode()
+
+	return nil
+}
+
 func (p facebookOAuthProvider) Initialize(opts *oAuthOptions, scopes ...string) (*OAuthRequestResult, error) {
+	return p.InitializeContext(context.Background(), opts, scopes...)
+}
+
+func (p facebookOAuthProvider) InitializeContext(ctx context.Context, opts *oAuthOptions, scopes ...string) (*OAuthRequestResult, error) {
 	rScopes := ResolveScopes(
 		[]string{
 			"public_profile",
@@ -32,13 +57,21 @@ func (p facebookOAuthProvider) Initialize(opts *oAuthOptions, scopes ...string)
 		"email",
 	)
 
+	authType := opts.AuthType
+	if len(authType) < 1 && (opts.Prompt == "login" || opts.MaxAge > 0) {
+		authType = "reauthenticate"
+	}
+
 	uri := p.client.Clone().SetHost("www.facebook.com").
 		SetPath(fmt.Sprintf("%s/dialog/oauth", p.version)).
 		Set("client_id", p.config.ClientID()).
 		Set("response_type", "code").
-		SetIf(len(opts.AuthType) > 0, "auth_type", opts.AuthType).
+		SetIf(len(authType) > 0, "auth_type", authType).
 		SetIf(len(rScopes) > 0, "scope", strings.Join(rScopes, ",")).
-		Set("redirect_uri", opts.Redirect)
+		Set("redirect_uri", opts.Redirect).
+		SetIf(len(opts.State) > 0, "state", opts.State).
+		SetIf(len(opts.CodeChallenge) > 0, "code_challenge", opts.CodeChallenge).
+		SetIf(len(opts.CodeChallenge) > 0, "code_challenge_method", opts.CodeChallengeMethod)
 
 	return &OAuthRequestResult{
 		Type: OAuthRequestRedirect,
@@ -47,13 +80,25 @@ func (p facebookOAuthProvider) Initialize(opts *oAuthOptions, scopes ...string)
 }
 
 func (p facebookOAuthProvider) Callback(opts *oAuthOptions) (*OAuthToken, error) {
-	data, err := p.RunTokenRequest(func(uri *oAuthURI) *oAuthURI {
+	return p.CallbackContext(context.Background(), opts)
+}
+
+func (p facebookOAuthProvider) CallbackContext(ctx context.Context, opts *oAuthOptions) (*OAuthToken, error) {
+	code, err := opts.GetConfigString("code")
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := p.RunTokenRequestContext(ctx, func(uri *oAuthURI) *oAuthURI {
 		return uri.SetPath(fmt.Sprintf("%s/oauth/access_token", p.version)).
 			Set("client_id", p.config.ClientID()).
 			Set("client_secret", p.config.ClientSecret()).
-			Set("code", opts.GetConfig("code").(string)).
-			Set("redirect_uri", opts.Redirect)
-	}, RequestOptions(), "access_token")
+			Set("code", code).
+			Set("redirect_uri", opts.Redirect).
+			SetIf(len(opts.CodeVerifier) > 0, "code_verifier", opts.CodeVerifier)
+	}, RequestOptions(
+		WithReqOptRetry(RetryConnectOnly),
+	), "access_token")
 
 	if err != nil {
 		return nil, err
@@ -62,7 +107,11 @@ func (p facebookOAuthProvider) Callback(opts *oAuthOptions) (*OAuthToken, error)
 }
 
 func (p facebookOAuthProvider) RefreshToken(token string) (*OAuthToken, error) {
-	data, err := p.RunTokenRequest(func(uri *oAuthURI) *oAuthURI {
+	return p.RefreshTokenContext(context.Background(), token)
+}
+
+func (p facebookOAuthProvider) RefreshTokenContext(ctx context.Context, token string) (*OAuthToken, error) {
+	data, err := p.RunTokenRequestContext(ctx, func(uri *oAuthURI) *oAuthURI {
 		return uri.SetPath(fmt.Sprintf("%s/oauth/access_token", p.version)).
 			Set("client_id", p.config.ClientID()).
 			Set("client_secret", p.config.ClientSecret()).
@@ -77,7 +126,11 @@ func (p facebookOAuthProvider) RefreshToken(token string) (*OAuthToken, error) {
 }
 
 func (p facebookOAuthProvider) TokenToUser(token *OAuthToken) (*OAuthUser, error) {
-	fields, err := p.Get(token.AccessToken, []string{"id", "email"}, Options())
+	return p.TokenToUserContext(context.Background(), token)
+}
+
+func (p facebookOAuthProvider) TokenToUserContext(ctx context.Context, token *OAuthToken) (*OAuthUser, error) {
+	fields, err := p.GetContext(ctx, token.AccessToken, []string{"id", "email"}, Options())
 	if err != nil {
 		return nil, err
 	}
@@ -92,24 +145,28 @@ func (p facebookOAuthProvider) TokenToUser(token *OAuthToken) (*OAuthUser, error
 }
 
 func (p facebookOAuthProvider) Get(token string, fields []string, opts *oAuthOptions) (types.JSONDumpData, error) {
+	return p.GetContext(context.Background(), token, fields, opts)
+}
+
+func (p facebookOAuthProvider) GetContext(ctx context.Context, token string, fields []string, opts *oAuthOptions) (types.JSONDumpData, error) {
 	path := "me"
 	if len(opts.RequestPath) > 0 {
 		path = opts.RequestPath
 	}
 
-	return p.Call(func(uri *oAuthURI) *oAuthURI {
+	return p.CallContext(ctx, func(uri *oAuthURI) *oAuthURI {
 		return uri.SetPath(path).
 			Set("access_token", token).
 			Set("fields", strings.Join(fields, ","))
 	}, RequestOptions())
 }
 
-func FacebookOAuth(config OAuthConfig) *facebookOAuthProvider {
+func FacebookOAuth(config OAuthConfig, cbs ...OAuthProviderCallback) *facebookOAuthProvider {
 	return &facebookOAuthProvider{
 		version: "v21.0",
-		OAuthProviderBase: &OAuthProviderBase{
+		OAuthProviderBase: (&OAuthProviderBase{
 			config: config,
 			client: URIHost("graph.facebook.com"),
-		},
+		}).apply(cbs),
 	}
 }
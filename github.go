@@ -0,0 +1,260 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/DecxBase/core/types"
+	"github.com/DecxBase/core/utils"
+)
+
+const gitHubAPIVersion = "2022-11-28"
+
+type githubOAuthProvider struct {
+	*OAuthProviderBase
+	api *oAuthURI
+}
+
+func (p githubOAuthProvider) Name() string {
+	return "github"
+}
+
+func (p githubOAuthProvider) FieldMappings() utils.DataMap[string] {
+	return utils.MakeDataMap(map[string]string{
+		"username":  "login",
+		"full_name": "name",
+		"avatar":    "avatar_url",
+	})
+}
+
+func (p githubOAuthProvider) web(to string) *oAuthURI {
+	return p.client.Clone().SetPath(path.Join(p.client.Path, to))
+}
+
+func (p githubOAuthProvider) apiCall(ctx context.Context, token string, to string, opts *oAuthRequestOptions) (types.JSONDumpData, error) {
+	WithReqHeader("Accept", "application/vnd.github+json")(opts)
+	WithReqHeader("X-GitHub-Api-Version", gitHubAPIVersion)(opts)
+	if len(token) > 0 {
+		WithReqHeader("Authorization", fmt.Sprintf("Bearer %s", token))(opts)
+	}
+
+	return p.CallContext(ctx, func(uri *oAuthURI) *oAuthURI {
+		return p.api.Clone().SetPath(path.Join(p.api.Path, to))
+	}, opts)
+}
+
+func (p githubOAuthProvider) Initialize(opts *oAuthOptions, scopes ...string) (*OAuthRequestResult, error) {
+	return p.InitializeContext(context.Background(), opts, scopes...)
+}
+
+func (p githubOAuthProvider) InitializeContext(ctx context.Context, opts *oAuthOptions, scopes ...string) (*OAuthRequestResult, error) {
+	rScopes := ResolveScopes(
+		[]string{
+			"read:user",
+		},
+		scopes,
+		"user:email",
+	)
+
+	uri := p.web("login/oauth/authorize").
+		Set("client_id", p.config.ClientID()).
+		Set("scope", strings.Join(rScopes, " ")).
+		Set("redirect_uri", opts.Redirect).
+		SetIf(len(opts.State) > 0, "state", opts.State).
+		SetIf(len(opts.Prompt) > 0, "prompt", opts.Prompt).
+		SetIf(len(opts.CodeChallenge) > 0, "code_challenge", opts.CodeChallenge).
+		SetIf(len(opts.CodeChallenge) > 0, "code_challenge_method", opts.CodeChallengeMethod)
+
+	return &OAuthRequestResult{
+		Type: OAuthRequestRedirect,
+		Data: uri.String(),
+	}, nil
+}
+
+func (p githubOAuthProvider) tokenRequest(ctx context.Context, cb OAuthRawCallback) (*OAuthToken, error) {
+	data, err := p.RunTokenRequestContext(ctx, func(uri *oAuthURI) *oAuthURI {
+		return cb(p.web("login/oauth/access_token")).
+			SetBody("client_id", p.config.ClientID()).
+			SetBody("client_secret", p.config.ClientSecret())
+	}, RequestOptions(
+		WithReqOptMethod(http.MethodPost),
+		WithReqOptForm(),
+		WithReqHeader("Accept", "application/json"),
+		// refresh tokens are single use, so neither request may be sent twice
+		WithReqOptRetry(RetryConnectOnly),
+	), "access_token")
+
+	if err != nil {
+		return nil, err
+	}
+	return p.MakeTokenResult(data)
+}
+
+func (p githubOAuthProvider) Callback(opts *oAuthOptions) (*OAuthToken, error) {
+	return p.CallbackContext(context.Background(), opts)
+}
+
+func (p githubOAuthProvider) CallbackContext(ctx context.Context, opts *oAuthOptions) (*OAuthToken, error) {
+	code, err := opts.GetConfigString("code")
+	if err != nil {
+		return nil, err
+	}
+
+	return p.tokenRequest(ctx, func(uri *oAuthURI) *oAuthURI {
+		return uri.SetBody("code", code).
+			SetBody("redirect_uri", opts.Redirect).
+			SetBodyIf(len(opts.CodeVerifier) > 0, "code_verifier", opts.CodeVerifier)
+	})
+}
+
+func (p githubOAuthProvider) RefreshToken(token string) (*OAuthToken, error) {
+	return p.RefreshTokenContext(context.Background(), token)
+}
+
+func (p githubOAuthProvider) RefreshTokenContext(ctx context.Context, token string) (*OAuthToken, error) {
+	return p.tokenRequest(ctx, func(uri *oAuthURI) *oAuthURI {
+		return uri.SetBody("grant_type", "refresh_token").
+			SetBody("refresh_token", token)
+	})
+}
+
+// PrimaryEmail picks the primary verified address from /user/emails
+func (p githubOAuthProvider) PrimaryEmail(ctx context.Context, token string) (string, error) {
+	data, err := p.apiCall(ctx, token, "user/emails", RequestOptions())
+	if err != nil {
+		return "", err
+	}
+
+	emails, _ := data["data"].([]any)
+	for _, item := range emails {
+		entry, _ := item.(map[string]any)
+		primary, _ := entry["primary"].(bool)
+		verified, _ := entry["verified"].(bool)
+		email, _ := entry["email"].(string)
+
+		if primary && verified && len(email) > 0 {
+			return email, nil
+		}
+	}
+
+	return "", nil
+}
+
+func (p githubOAuthProvider) TokenToUser(token *OAuthToken) (*OAuthUser, error) {
+	return p.TokenToUserContext(context.Background(), token)
+}
+
+func (p githubOAuthProvider) TokenToUserContext(ctx context.Context, token *OAuthToken) (*OAuthUser, error) {
+	profile, err := p.apiCall(ctx, token.AccessToken, "user", RequestOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := profile["id"].(float64)
+	if !ok {
+		return nil, OAuthErrorDecodeFailed()
+	}
+
+	// a private profile email comes back as null, /user/emails still lists it
+	email, _ := profile["email"].(string)
+	if len(email) < 1 {
+		email, err = p.PrimaryEmail(ctx, token.AccessToken)
+
+		// the token lacks user:email, or the GitHub App lacks the email permission
+		if status := OAuthErrorStatus(err); status == http.StatusForbidden || status == http.StatusNotFound {
+			email, err = "", nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	user := &OAuthUser{
+		UserID:       strconv.FormatFloat(id, 'f', -1, 64),
+		IdentityType: "email",
+		Identity:     email,
+		AccessToken:  token.AccessToken,
+		ExpiresIn:    token.ExpiresIn,
+		Profile:      profile,
+	}
+
+	if len(email) < 1 {
+		user.IdentityType = "username"
+		user.Identity, _ = profile["login"].(string)
+	}
+
+	return user, nil
+}
+
+func (p githubOAuthProvider) RevokeToken(token string) error {
+	return p.RevokeTokenContext(context.Background(), token)
+}
+
+func (p githubOAuthProvider) RevokeTokenContext(ctx context.Context, token string) error {
+	_, err := p.CallContext(ctx, func(uri *oAuthURI) *oAuthURI {
+		return p.api.Clone().SetPath(path.Join(p.api.Path, "applications", p.config.ClientID(), "token")).
+			SetBody("access_token", token)
+	}, RequestOptions(
+		WithReqOptMethod(http.MethodDelete),
+		WithReqBasicAuth(p.config.ClientID(), p.config.ClientSecret()),
+		WithReqHeader("Accept", "application/vnd.github+json"),
+		WithReqHeader("X-GitHub-Api-Version", gitHubAPIVersion),
+	))
+
+	return err
+}
+
+func (p githubOAuthProvider) Get(token string, fields []string, opts *oAuthOptions) (types.JSONDumpData, error) {
+	return p.GetContext(context.Background(), token, fields, opts)
+}
+
+func (p githubOAuthProvider) GetContext(ctx context.Context, token string, fields []string, opts *oAuthOptions) (types.JSONDumpData, error) {
+	to := "user"
+	if len(opts.RequestPath) > 0 {
+		to = opts.RequestPath
+	}
+
+	data, err := p.apiCall(ctx, token, to, RequestOptions())
+	if err != nil || len(fields) < 1 {
+		return data, err
+	}
+
+	result := make(types.JSONDumpData, len(fields))
+	for _, field := range fields {
+		if val, ok := data[field]; ok {
+			result[field] = val
+		}
+	}
+
+	return result, nil
+}
+
+// GitHubOAuth talks to github.com, or to GitHub Enterprise Server when the config
+// carries a base URL (see WithBaseURL), whose REST API lives under /api/v3. An invalid
+// base URL is an error, the client credentials never go to github.com instead.
+func GitHubOAuth(config OAuthConfig, cbs ...OAuthProviderCallback) (*githubOAuthProvider, error) {
+	client, api := URIHost("github.com"), URIHost("api.github.com")
+
+	if base, ok := configString(config, ConfigBaseURL); ok {
+		parsed, err := URIBase(base)
+		if err != nil {
+			return nil, OAuthErrorInvalidConfig(ConfigBaseURL)
+		}
+
+		client = parsed
+		api = parsed.Clone().Join("api/v3")
+	}
+
+	return &githubOAuthProvider{
+		api: api,
+		OAuthProviderBase: (&OAuthProviderBase{
+			config: config,
+			client: client,
+		}).apply(cbs),
+	}, nil
+}
@@ -0,0 +1,208 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/DecxBase/core/types"
+	"github.com/DecxBase/core/utils"
+)
+
+type gitlabOAuthProvider struct {
+	*OAuthProviderBase
+}
+
+func (p gitlabOAuthProvider) Name() string {
+	return "gitlab"
+}
+
+func (p gitlabOAuthProvider) FieldMappings() utils.DataMap[string] {
+	return utils.MakeDataMap(map[string]string{
+		"full_name": "name",
+		"avatar":    "avatar_url",
+	})
+}
+
+func (p gitlabOAuthProvider) Initialize(opts *oAuthOptions, scopes ...string) (*OAuthRequestResult, error) {
+	return p.InitializeContext(context.Background(), opts, scopes...)
+}
+
+func (p gitlabOAuthProvider) InitializeContext(ctx context.Context, opts *oAuthOptions, scopes ...string) (*OAuthRequestResult, error) {
+	rScopes := ResolveScopes(
+		[]string{
+			"openid",
+			"read_user",
+		},
+		scopes,
+		"profile",
+		"email",
+	)
+
+	uri := p.client.Clone().Join("oauth/authorize").
+		Set("client_id", p.config.ClientID()).
+		Set("response_type", "code").
+		Set("scope", strings.Join(rScopes, " ")).
+		Set("redirect_uri", opts.Redirect).
+		SetIf(len(opts.State) > 0, "state", opts.State).
+		SetIf(len(opts.Nonce) > 0, "nonce", opts.Nonce).
+		SetIf(len(opts.Prompt) > 0, "prompt", opts.Prompt).
+		SetIf(len(opts.CodeChallenge) > 0, "code_challenge", opts.CodeChallenge).
+		SetIf(len(opts.CodeChallenge) > 0, "code_challenge_method", opts.CodeChallengeMethod)
+
+	return &OAuthRequestResult{
+		Type: OAuthRequestRedirect,
+		Data: uri.String(),
+	}, nil
+}
+
+func (p gitlabOAuthProvider) tokenRequest(ctx context.Context, cb OAuthRawCallback) (*OAuthToken, error) {
+	data, err := p.RunTokenRequestContext(ctx, func(uri *oAuthURI) *oAuthURI {
+		return cb(uri.Join("oauth/token")).
+			SetBody("client_id", p.config.ClientID()).
+			SetBody("client_secret", p.config.ClientSecret())
+	}, RequestOptions(
+		WithReqOptMethod(http.MethodPost),
+		WithReqOptForm(),
+		// GitLab rotates refresh tokens, the old one is dead once a request got through
+		WithReqOptRetry(RetryConnectOnly),
+	), "access_token")
+
+	if err != nil {
+		return nil, err
+	}
+	return p.MakeTokenResult(data)
+}
+
+func (p gitlabOAuthProvider) Callback(opts *oAuthOptions) (*OAuthToken, error) {
+	return p.CallbackContext(context.Background(), opts)
+}
+
+func (p gitlabOAuthProvider) CallbackContext(ctx context.Context, opts *oAuthOptions) (*OAuthToken, error) {
+	code, err := opts.GetConfigString("code")
+	if err != nil {
+		return nil, err
+	}
+
+	return p.tokenRequest(ctx, func(uri *oAuthURI) *oAuthURI {
+		return uri.SetBody("grant_type", "authorization_code").
+			SetBody("code", code).
+			SetBody("redirect_uri", opts.Redirect).
+			SetBodyIf(len(opts.CodeVerifier) > 0, "code_verifier", opts.CodeVerifier)
+	})
+}
+
+func (p gitlabOAuthProvider) RefreshToken(token string) (*OAuthToken, error) {
+	return p.RefreshTokenContext(context.Background(), token)
+}
+
+func (p gitlabOAuthProvider) RefreshTokenContext(ctx context.Context, token string) (*OAuthToken, error) {
+	return p.tokenRequest(ctx, func(uri *oAuthURI) *oAuthURI {
+		return uri.SetBody("grant_type", "refresh_token").
+			SetBody("refresh_token", token)
+	})
+}
+
+func (p gitlabOAuthProvider) TokenToUser(token *OAuthToken) (*OAuthUser, error) {
+	return p.TokenToUserContext(context.Background(), token)
+}
+
+func (p gitlabOAuthProvider) TokenToUserContext(ctx context.Context, token *OAuthToken) (*OAuthUser, error) {
+	profile, err := p.GetContext(ctx, token.AccessToken, nil, Options())
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := profile["id"].(float64)
+	if !ok {
+		return nil, OAuthErrorDecodeFailed()
+	}
+
+	user := &OAuthUser{
+		UserID:       strconv.FormatFloat(id, 'f', -1, 64),
+		IdentityType: "email",
+		AccessToken:  token.AccessToken,
+		ExpiresIn:    token.ExpiresIn,
+		Profile:      profile,
+	}
+
+	user.Identity, _ = profile["email"].(string)
+	if len(user.Identity) < 1 {
+		user.IdentityType = "username"
+		user.Identity, _ = profile["username"].(string)
+	}
+
+	return user, nil
+}
+
+func (p gitlabOAuthProvider) RevokeToken(token string) error {
+	return p.RevokeTokenContext(context.Background(), token)
+}
+
+func (p gitlabOAuthProvider) RevokeTokenContext(ctx context.Context, token string) error {
+	_, err := p.CallContext(ctx, func(uri *oAuthURI) *oAuthURI {
+		return uri.Join("oauth/revoke").
+			SetBody("client_id", p.config.ClientID()).
+			SetBody("client_secret", p.config.ClientSecret()).
+			SetBody("token", token)
+	}, RequestOptions(
+		WithReqOptMethod(http.MethodPost),
+		WithReqOptForm(),
+	))
+
+	return err
+}
+
+func (p gitlabOAuthProvider) Get(token string, fields []string, opts *oAuthOptions) (types.JSONDumpData, error) {
+	return p.GetContext(context.Background(), token, fields, opts)
+}
+
+func (p gitlabOAuthProvider) GetContext(ctx context.Context, token string, fields []string, opts *oAuthOptions) (types.JSONDumpData, error) {
+	path := "user"
+	if len(opts.RequestPath) > 0 {
+		path = opts.RequestPath
+	}
+
+	data, err := p.CallContext(ctx, func(uri *oAuthURI) *oAuthURI {
+		return uri.Join("api/v4").Join(path)
+	}, RequestOptions(
+		WithReqHeader("Authorization", fmt.Sprintf("Bearer %s", token)),
+	))
+	if err != nil || len(fields) < 1 {
+		return data, err
+	}
+
+	result := make(types.JSONDumpData, len(fields))
+	for _, field := range fields {
+		if val, ok := data[field]; ok {
+			result[field] = val
+		}
+	}
+
+	return result, nil
+}
+
+// GitLabOAuth works against gitlab.com when `baseURL` is empty, or against the given
+// self-managed instance, which may use plain http, a custom port or a sub path. An
+// invalid base URL is an error rather than a silent fallback to gitlab.com.
+func GitLabOAuth(config OAuthConfig, baseURL string, cbs ...OAuthProviderCallback) (*gitlabOAuthProvider, error) {
+	if len(baseURL) < 1 {
+		baseURL = "https://gitlab.com"
+	}
+
+	client, err := URIBase(baseURL)
+	if err != nil {
+		return nil, OAuthErrorInvalidConfig(ConfigBaseURL)
+	}
+
+	return &gitlabOAuthProvider{
+		OAuthProviderBase: (&OAuthProviderBase{
+			config:  config,
+			client:  client,
+			keys:    JWKS(client.Clone().Join("oauth/discovery/keys").String()),
+			issuers: []string{client.String()},
+		}).apply(cbs),
+	}, nil
+}
@@ -2,15 +2,17 @@ module github.com/DecxBase/oauth
 
 go 1.23.3
 
-require github.com/DecxBase/core v0.0.2
+require (
+	github.com/DecxBase/core v0.0.2
+	github.com/gorilla/schema v1.4.1
+	github.com/uptrace/bun v1.2.5
+)
 
 require (
-	github.com/gorilla/schema v1.4.1 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/phuslu/log v1.0.113 // indirect
 	github.com/puzpuzpuz/xsync/v3 v3.4.0 // indirect
 	github.com/tmthrgd/go-hex v0.0.0-20190904060850-447a3041c3bc // indirect
-	github.com/uptrace/bun v1.2.5 // indirect
 	github.com/vmihailenco/msgpack/v5 v5.4.1 // indirect
 	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 	golang.org/x/net v0.31.0 // indirect
@@ -1,6 +1,7 @@
 package oauth
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strings"
@@ -38,6 +39,10 @@ func (p googleOAuthProvider) Validate(data types.JSONStringData) error {
 }
 
 func (p googleOAuthProvider) Initialize(opts *oAuthOptions, scopes ...string) (*OAuthRequestResult, error) {
+	return p.InitializeContext(context.Background(), opts, scopes...)
+}
+
+func (p googleOAuthProvider) InitializeContext(ctx context.Context, opts *oAuthOptions, scopes ...string) (*OAuthRequestResult, error) {
 	rScopes := ResolveScopes(
 		[]string{
 			"https://www.googleapis.com/auth/userinfo.profile",
@@ -50,7 +55,13 @@ func (p googleOAuthProvider) Initialize(opts *oAuthOptions, scopes ...string) (*
 		Set("client_id", p.config.ClientID()).
 		Set("response_type", "code").
 		Set("scope", strings.Join(rScopes, " ")).
-		Set("redirect_uri", opts.Redirect)
+		Set("redirect_uri", opts.Redirect).
+		SetIf(len(opts.State) > 0, "state", opts.State).
+		SetIf(len(opts.Nonce) > 0, "nonce", opts.Nonce).
+		SetIf(len(opts.Prompt) > 0, "prompt", opts.Prompt).
+		SetIf(opts.MaxAge > 0, "max_age", opts.maxAgeSeconds()).
+		SetIf(len(opts.CodeChallenge) > 0, "code_challenge", opts.CodeChallenge).
+		SetIf(len(opts.CodeChallenge) > 0, "code_challenge_method", opts.CodeChallengeMethod)
 
 	return &OAuthRequestResult{
 		Type: OAuthRequestRedirect,
@@ -59,15 +70,26 @@ func (p googleOAuthProvider) Initialize(opts *oAuthOptions, scopes ...string) (*
 }
 
 func (p googleOAuthProvider) Callback(opts *oAuthOptions) (*OAuthToken, error) {
-	data, err := p.RunTokenRequest(func(uri *oAuthURI) *oAuthURI {
+	return p.CallbackContext(context.Background(), opts)
+}
+
+func (p googleOAuthProvider) CallbackContext(ctx context.Context, opts *oAuthOptions) (*OAuthToken, error) {
+	code, err := opts.GetConfigString("code")
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := p.RunTokenRequestContext(ctx, func(uri *oAuthURI) *oAuthURI {
 		return uri.SetPath("o/oauth2/token").
 			SetBody("client_id", p.config.ClientID()).
 			SetBody("client_secret", p.config.ClientSecret()).
 			SetBody("grant_type", "authorization_code").
-			SetBody("code", opts.GetConfig("code").(string)).
-			SetBody("redirect_uri", opts.Redirect)
+			SetBody("code", code).
+			SetBody("redirect_uri", opts.Redirect).
+			SetBodyIf(len(opts.CodeVerifier) > 0, "code_verifier", opts.CodeVerifier)
 	}, RequestOptions(
 		WithReqOptMethod(http.MethodPost),
+		WithReqOptRetry(RetryConnectOnly),
 	), "access_token")
 
 	if err != nil {
@@ -77,7 +99,11 @@ func (p googleOAuthProvider) Callback(opts *oAuthOptions) (*OAuthToken, error) {
 }
 
 func (p googleOAuthProvider) RefreshToken(token string) (*OAuthToken, error) {
-	data, err := p.RunTokenRequest(func(uri *oAuthURI) *oAuthURI {
+	return p.RefreshTokenContext(context.Background(), token)
+}
+
+func (p googleOAuthProvider) RefreshTokenContext(ctx context.Context, token string) (*OAuthToken, error) {
+	data, err := p.RunTokenRequestContext(ctx, func(uri *oAuthURI) *oAuthURI {
 		return uri.SetPath("o/oauth2/token").
 			SetBody("client_id", p.config.ClientID()).
 			SetBody("client_secret", p.config.ClientSecret()).
@@ -87,6 +113,7 @@ func (p googleOAuthProvider) RefreshToken(token string) (*OAuthToken, error) {
 			SetBody("refresh_token", token)
 	}, RequestOptions(
 		WithReqOptMethod(http.MethodPost),
+		WithReqOptRetry(RetrySafe),
 	), "access_token")
 
 	if err != nil {
@@ -96,7 +123,11 @@ func (p googleOAuthProvider) RefreshToken(token string) (*OAuthToken, error) {
 }
 
 func (p googleOAuthProvider) TokenToUser(token *OAuthToken) (*OAuthUser, error) {
-	payload, err := p.DecodeIDToken(token.IDToken)
+	return p.TokenToUserContext(context.Background(), token)
+}
+
+func (p googleOAuthProvider) TokenToUserContext(ctx context.Context, token *OAuthToken) (*OAuthUser, error) {
+	payload, err := p.IDTokenClaims(ctx, token.IDToken)
 	if err != nil {
 		return nil, err
 	}
@@ -110,8 +141,29 @@ func (p googleOAuthProvider) TokenToUser(token *OAuthToken) (*OAuthUser, error)
 	}, nil
 }
 
+func (p googleOAuthProvider) RevokeToken(token string) error {
+	return p.RevokeTokenContext(context.Background(), token)
+}
+
+func (p googleOAuthProvider) RevokeTokenContext(ctx context.Context, token string) error {
+	_, err := p.CallContext(ctx, func(uri *oAuthURI) *oAuthURI {
+		return uri.SetHost("oauth2.googleapis.com").
+			SetPath("revoke").
+			SetBody("token", token)
+	}, RequestOptions(
+		WithReqOptMethod(http.MethodPost),
+		WithReqOptForm(),
+	))
+
+	return err
+}
+
 func (p googleOAuthProvider) Get(token string, fields []string, opts *oAuthOptions) (types.JSONDumpData, error) {
-	data, err := p.Call(func(uri *oAuthURI) *oAuthURI {
+	return p.GetContext(context.Background(), token, fields, opts)
+}
+
+func (p googleOAuthProvider) GetContext(ctx context.Context, token string, fields []string, opts *oAuthOptions) (types.JSONDumpData, error) {
+	data, err := p.CallContext(ctx, func(uri *oAuthURI) *oAuthURI {
 		return uri.SetHost("www.googleapis.com").
 			SetPath("oauth2/v1/userinfo").
 			SetBody("alt", "json")
@@ -125,11 +177,16 @@ func (p googleOAuthProvider) Get(token string, fields []string, opts *oAuthOptio
 	return utils.PluckFields(data, fields), nil
 }
 
-func GoogleOAuth(config OAuthConfig) *googleOAuthProvider {
+func GoogleOAuth(config OAuthConfig, cbs ...OAuthProviderCallback) *googleOAuthProvider {
 	return &googleOAuthProvider{
-		OAuthProviderBase: &OAuthProviderBase{
+		OAuthProviderBase: (&OAuthProviderBase{
 			config: config,
 			client: URIHost("accounts.google.com"),
-		},
+			keys:   JWKS("https://www.googleapis.com/oauth2/v3/certs"),
+			issuers: []string{
+				"https://accounts.google.com",
+				"accounts.google.com",
+			},
+		}).apply(cbs),
 	}
 }
@@ -0,0 +1,349 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const DefaultStateCookie = "oauth_state"
+
+type OAuthHandlerSuccess = func(http.ResponseWriter, *http.Request, *OAuthToken, *OAuthUser, *OAuthState)
+
+type OAuthHandlerFailure = func(http.ResponseWriter, *http.Request, error)
+
+type oAuthHandlerOptions struct {
+	Success       OAuthHandlerSuccess
+	Failure       OAuthHandlerFailure
+	ErrorRedirect string
+	RedirectBase  string
+	CookieName    string
+	SameSite      http.SameSite
+	Scopes        map[string][]string
+	Options       []OAuthOptionCallback
+	Sessions      *oAuthSessionManager
+}
+
+type OAuthHandlerCallback = func(*oAuthHandlerOptions)
+
+func WithHandlerSuccess(success OAuthHandlerSuccess) OAuthHandlerCallback {
+	return func(o *oAuthHandlerOptions) {
+		o.Success = success
+	}
+}
+
+func WithHandlerFailure(failure OAuthHandlerFailure) OAuthHandlerCallback {
+	return func(o *oAuthHandlerOptions) {
+		o.Failure = failure
+	}
+}
+
+// WithHandlerErrorRedirect sends failed logins to `target` with `error` and `error_description` appended
+func WithHandlerErrorRedirect(target string) OAuthHandlerCallback {
+	return func(o *oAuthHandlerOptions) {
+		o.ErrorRedirect = target
+	}
+}
+
+// WithHandlerRedirectBase sets the public origin the callback URL is built on. Without it the
+// origin is taken from the request's Host header, which needs a redirect allowlist to vouch for it
+func WithHandlerRedirectBase(base string) OAuthHandlerCallback {
+	return func(o *oAuthHandlerOptions) {
+		o.RedirectBase = strings.TrimSuffix(base, "/")
+	}
+}
+
+func WithHandlerCookie(name string) OAuthHandlerCallback {
+	return func(o *oAuthHandlerOptions) {
+		o.CookieName = name
+	}
+}
+
+// WithHandlerSameSite relaxes the state cookie, providers answering with response_mode=form_post
+// need http.SameSiteNoneMode since their callback arrives as a cross-site POST
+func WithHandlerSameSite(mode http.SameSite) OAuthHandlerCallback {
+	return func(o *oAuthHandlerOptions) {
+		o.SameSite = mode
+	}
+}
+
+func WithHandlerScopes(provider string, scopes ...string) OAuthHandlerCallback {
+	return func(o *oAuthHandlerOptions) {
+		o.Scopes[provider] = scopes
+	}
+}
+
+// WithHandlerSessions starts a session on success unless a success function is set, and is
+// where RequireAuth looks users up
+func WithHandlerSessions(sessions *oAuthSessionManager) OAuthHandlerCallback {
+	return func(o *oAuthHandlerOptions) {
+		o.Sessions = sessions
+	}
+}
+
+func WithHandlerOptions(cbs ...OAuthOptionCallback) OAuthHandlerCallback {
+	return func(o *oAuthHandlerOptions) {
+		o.Options = append(o.Options, cbs...)
+	}
+}
+
+type oAuthHandler[T comparable] struct {
+	*http.ServeMux
+	service  *OAuthService[T]
+	basePath string
+	opts     *oAuthHandlerOptions
+}
+
+func (h *oAuthHandler[T]) provider(r *http.Request) (T, string, error) {
+	value := r.PathValue("provider")
+	for name := range h.service.Providers {
+		if h.service.providerKey(name) == value {
+			return name, value, nil
+		}
+	}
+
+	var empty T
+	return empty, value, OAuthError{
+		Reason:  "provider",
+		Message: "Unknown provider: " + value,
+	}
+}
+
+func (h *oAuthHandler[T]) redirectURI(r *http.Request, provider string) (string, error) {
+	path := h.basePath + "/callback/" + url.PathEscape(provider)
+	if len(h.opts.RedirectBase) > 0 {
+		return h.opts.RedirectBase + path, nil
+	}
+
+	// the Host header is client controlled, the service checks the result against the allowlist
+	if Options(h.service.makeOptionCallbacks(h.options())...).RedirectAllowlist == nil {
+		return "", OAuthErrorInvalidConfig("redirect_base")
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return scheme + "://" + r.Host + path, nil
+}
+
+func (h *oAuthHandler[T]) cookiePath() string {
+	return h.basePath + "/callback/"
+}
+
+func (h *oAuthHandler[T]) options(cbs ...OAuthOptionCallback) []OAuthOptionCallback {
+	return append(append(make([]OAuthOptionCallback, 0), h.opts.Options...), cbs...)
+}
+
+func (h *oAuthHandler[T]) login(w http.ResponseWriter, r *http.Request) {
+	name, key, err := h.provider(r)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	h.begin(w, r, name, key, h.opts.Scopes[key], WithOptReturnTo(r.URL.Query().Get("return_to")))
+}
+
+// begin sends the browser to the provider, the callback handler completes the flow
+func (h *oAuthHandler[T]) begin(w http.ResponseWriter, r *http.Request, name T, key string, scopes []string, cbs ...OAuthOptionCallback) {
+	redirect, err := h.redirectURI(r, key)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	result, err := h.service.InitializeContext(r.Context(), name, scopes, h.options(
+		append([]OAuthOptionCallback{WithOptRedirect(redirect)}, cbs...)...,
+	)...)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	// binds the state to this browser, the callback refuses any other value
+	http.SetCookie(w, &http.Cookie{
+		Name:     h.opts.CookieName,
+		Value:    result.State,
+		Path:     h.cookiePath(),
+		MaxAge:   int(DefaultStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   h.opts.SameSite == http.SameSiteNoneMode || r.TLS != nil || strings.HasPrefix(h.opts.RedirectBase, "https:"),
+		SameSite: h.opts.SameSite,
+	})
+
+	if result.Type == OAuthRequestData {
+		writeJSON(w, http.StatusOK, result.Data)
+		return
+	}
+
+	target, _ := result.Data.(string)
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+func (h *oAuthHandler[T]) callback(w http.ResponseWriter, r *http.Request) {
+	name, key, err := h.provider(r)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	redirect, err := h.redirectURI(r, key)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	cookie, err := r.Cookie(h.opts.CookieName)
+	if err != nil || len(cookie.Value) < 1 {
+		h.fail(w, r, OAuthErrorStateMismatch())
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     h.opts.CookieName,
+		Path:     h.cookiePath(),
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	token, state, err := h.service.CallbackStateFromRequest(name, r, h.options(
+		WithOptState(cookie.Value),
+		WithOptRedirect(redirect),
+	)...)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	user, err := h.service.TokenToUserContext(r.Context(), name, token)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	h.opts.Success(w, r, token, user, state)
+}
+
+func (h *oAuthHandler[T]) fail(w http.ResponseWriter, r *http.Request, err error) {
+	if h.opts.Failure != nil {
+		h.opts.Failure(w, r, err)
+		return
+	}
+
+	// anything but an OAuthError may carry internals such as store or driver errors
+	status := HandlerErrorStatus(err)
+	reason, message := "server_error", http.StatusText(status)
+
+	var oauthErr OAuthError
+	if errors.As(err, &oauthErr) {
+		if len(oauthErr.Reason) > 0 {
+			reason = oauthErr.Reason
+		}
+		if len(oauthErr.Message) > 0 {
+			message = oauthErr.Message
+		}
+	}
+
+	if len(h.opts.ErrorRedirect) > 0 {
+		if target, perr := url.Parse(h.opts.ErrorRedirect); perr == nil {
+			query := target.Query()
+			query.Set("error", reason)
+			query.Set("error_description", message)
+			target.RawQuery = query.Encode()
+
+			http.Redirect(w, r, target.String(), http.StatusFound)
+			return
+		}
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		writeJSON(w, status, map[string]string{
+			"error":             reason,
+			"error_description": message,
+		})
+		return
+	}
+
+	http.Error(w, message, status)
+}
+
+// HandlerErrorStatus maps an error to the status the login handlers respond with
+func HandlerErrorStatus(err error) int {
+	var oauthErr OAuthError
+	if !errors.As(err, &oauthErr) {
+		return http.StatusInternalServerError
+	}
+
+	switch oauthErr.Reason {
+	case "provider":
+		return http.StatusNotFound
+	case "access_denied":
+		return http.StatusForbidden
+	case "invalid_state", "invalid_request", "invalid_redirect", "decode":
+		return http.StatusBadRequest
+	case "invalid_config", "encryption":
+		return http.StatusInternalServerError
+	}
+
+	// the provider itself failed or rejected us
+	if oauthErr.Status > 0 {
+		return http.StatusBadGateway
+	}
+
+	return http.StatusUnauthorized
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func defaultHandlerSuccess(w http.ResponseWriter, r *http.Request, token *OAuthToken, user *OAuthUser, state *OAuthState) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"user_id":       user.UserID,
+		"identity_type": user.IdentityType,
+		"identity":      user.Identity,
+	})
+}
+
+// Handler mounts `{basePath}/login/{provider}` and `{basePath}/callback/{provider}`,
+// state, PKCE and nonce are handled through the service's StateStore
+func (s *OAuthService[T]) Handler(basePath string, cbs ...OAuthHandlerCallback) *oAuthHandler[T] {
+	opts := &oAuthHandlerOptions{
+		CookieName: DefaultStateCookie,
+		SameSite:   http.SameSiteLaxMode,
+		Scopes:     make(map[string][]string),
+	}
+
+	for _, cb := range cbs {
+		cb(opts)
+	}
+
+	if opts.Success == nil && opts.Sessions != nil {
+		opts.Success = opts.Sessions.HandlerSuccess("/")
+	} else if opts.Success == nil {
+		opts.Success = defaultHandlerSuccess
+	}
+
+	handler := &oAuthHandler[T]{
+		ServeMux: http.NewServeMux(),
+		service:  s,
+		basePath: "/" + strings.Trim(basePath, "/"),
+		opts:     opts,
+	}
+
+	if handler.basePath == "/" {
+		handler.basePath = ""
+	}
+
+	handler.HandleFunc("GET "+handler.basePath+"/login/{provider}", handler.login)
+	handler.HandleFunc("GET "+handler.basePath+"/callback/{provider}", handler.callback)
+	handler.HandleFunc("POST "+handler.basePath+"/callback/{provider}", handler.callback)
+
+	return handler
+}
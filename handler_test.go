@@ -0,0 +1,195 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// handlerLogin runs the login step and returns the state cookie and the provider redirect
+func handlerLogin(t *testing.T, handler http.Handler, host string) (*http.Cookie, *url.URL) {
+	req := httptest.NewRequest(http.MethodGet, "http://"+host+"/auth/login/stub", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	target, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == DefaultStateCookie {
+			return cookie, target
+		}
+	}
+
+	t.Fatal("login didn't set the state cookie")
+	return nil, nil
+}
+
+func handlerCallback(handler http.Handler, state string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "https://app.test/auth/callback/stub?code=good&state="+url.QueryEscape(state), nil)
+	req.Header.Set("Accept", "application/json")
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestHandlerRoundTrip(t *testing.T) {
+	stores := map[string]StateStore{
+		"memory": MemoryStateStore(0),
+		"cookie": CookieStateStore([]byte("0123456789abcdef0123456789abcdef"), 0),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			handler := stubService(store).Handler("/auth", WithHandlerRedirectBase("https://app.test"))
+
+			cookie, target := handlerLogin(t, handler, "app.test")
+			if target.Host != "idp.test" || target.Query().Get("state") != cookie.Value {
+				t.Fatalf("provider redirect = %s, cookie %s", target, cookie.Value)
+			}
+
+			if redirect := target.Query().Get("redirect_uri"); redirect != "https://app.test/auth/callback/stub" {
+				t.Fatalf("redirect_uri = %s", redirect)
+			}
+
+			rec := handlerCallback(handler, cookie.Value, cookie)
+			if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"user_id":"1"`) {
+				t.Fatalf("callback = %d: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandlerCallbackState(t *testing.T) {
+	tests := []struct {
+		name  string
+		store StateStore
+		run   func(handler http.Handler, cookie *http.Cookie) *httptest.ResponseRecorder
+	}{
+		{
+			name:  "missing cookie",
+			store: MemoryStateStore(0),
+			run: func(handler http.Handler, cookie *http.Cookie) *httptest.ResponseRecorder {
+				return handlerCallback(handler, cookie.Value, nil)
+			},
+		},
+		{
+			name:  "other cookie",
+			store: MemoryStateStore(0),
+			run: func(handler http.Handler, cookie *http.Cookie) *httptest.ResponseRecorder {
+				return handlerCallback(handler, cookie.Value, &http.Cookie{Name: cookie.Name, Value: "other"})
+			},
+		},
+		{
+			name:  "missing state",
+			store: MemoryStateStore(0),
+			run: func(handler http.Handler, cookie *http.Cookie) *httptest.ResponseRecorder {
+				return handlerCallback(handler, "", cookie)
+			},
+		},
+		{
+			name:  "replayed",
+			store: MemoryStateStore(0),
+			run: func(handler http.Handler, cookie *http.Cookie) *httptest.ResponseRecorder {
+				handlerCallback(handler, cookie.Value, cookie)
+				return handlerCallback(handler, cookie.Value, cookie)
+			},
+		},
+		{
+			name:  "cookie store missing cookie",
+			store: CookieStateStore([]byte("0123456789abcdef0123456789abcdef"), 0),
+			run: func(handler http.Handler, cookie *http.Cookie) *httptest.ResponseRecorder {
+				return handlerCallback(handler, cookie.Value, nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := stubService(tt.store).Handler("/auth", WithHandlerRedirectBase("https://app.test"))
+
+			cookie, _ := handlerLogin(t, handler, "app.test")
+			rec := tt.run(handler, cookie)
+
+			if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"error":"invalid_state"`) {
+				t.Fatalf("callback = %d: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandlerRedirectHost(t *testing.T) {
+	tests := []struct {
+		name     string
+		cbs      []OAuthHandlerCallback
+		host     string
+		status   int
+		redirect string
+	}{
+		{
+			name:   "host without allowlist",
+			host:   "evil.test",
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:     "allowed host",
+			cbs:      []OAuthHandlerCallback{WithHandlerOptions(WithOptRedirectAllowlist(RedirectAllowlist("http://app.test/auth/callback/stub")))},
+			host:     "app.test",
+			status:   http.StatusFound,
+			redirect: "http://app.test/auth/callback/stub",
+		},
+		{
+			name:   "host outside the allowlist",
+			cbs:    []OAuthHandlerCallback{WithHandlerOptions(WithOptRedirectAllowlist(RedirectAllowlist("http://app.test/auth/callback/stub")))},
+			host:   "evil.test",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:     "redirect base ignores the host",
+			cbs:      []OAuthHandlerCallback{WithHandlerRedirectBase("https://app.test")},
+			host:     "evil.test",
+			status:   http.StatusFound,
+			redirect: "https://app.test/auth/callback/stub",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := stubService(MemoryStateStore(0)).Handler("/auth", tt.cbs...)
+
+			req := httptest.NewRequest(http.MethodGet, "http://"+tt.host+"/auth/login/stub", nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("login status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+
+			if len(tt.redirect) < 1 {
+				return
+			}
+
+			target, err := url.Parse(rec.Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if redirect := target.Query().Get("redirect_uri"); redirect != tt.redirect {
+				t.Errorf("redirect_uri = %s, want %s", redirect, tt.redirect)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package oauth
+
+import (
+	"crypto/subtle"
+	"time"
+
+	"github.com/DecxBase/core/types"
+	"github.com/DecxBase/core/utils"
+)
+
+const DefaultClockSkew = time.Minute
+
+type IDTokenValidator struct {
+	Issuers   []string
+	Audience  string
+	ClockSkew time.Duration
+	Now       func() time.Time
+	// SkipExpiry accepts expired tokens, for re-reading claims that were verified on callback
+	SkipExpiry bool
+}
+
+func (v IDTokenValidator) now() time.Time {
+	if v.Now != nil {
+		return v.Now()
+	}
+
+	return time.Now()
+}
+
+func (v IDTokenValidator) audiences(claims types.JSONDumpData) []string {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []any:
+		result := make([]string, 0, len(aud))
+		for _, item := range aud {
+			if val, ok := item.(string); ok {
+				result = append(result, val)
+			}
+		}
+		return result
+	}
+
+	return nil
+}
+
+func claimTime(claims types.JSONDumpData, key string) (time.Time, bool) {
+	val, ok := claims[key].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return time.Unix(int64(val), 0), true
+}
+
+func (v IDTokenValidator) Validate(claims types.JSONDumpData, nonce string) error {
+	now := v.now()
+
+	iss, _ := claims["iss"].(string)
+	if len(v.Issuers) > 0 && !utils.CheckContains(v.Issuers, iss) {
+		return OAuthErrorIDTokenIssuer()
+	}
+
+	audiences := v.audiences(claims)
+	if !utils.CheckContains(audiences, v.Audience) {
+		return OAuthErrorIDTokenAudience()
+	}
+
+	azp, hasAzp := claims["azp"].(string)
+	if (len(audiences) > 1 || hasAzp) && azp != v.Audience {
+		return OAuthErrorIDTokenAuthorizedParty()
+	}
+
+	exp, ok := claimTime(claims, "exp")
+	if !v.SkipExpiry && (!ok || now.Add(-v.ClockSkew).After(exp)) {
+		return OAuthErrorIDTokenExpired()
+	}
+
+	if nbf, ok := claimTime(claims, "nbf"); ok && now.Add(v.ClockSkew).Before(nbf) {
+		return OAuthErrorIDTokenNotYetValid()
+	}
+
+	iat, ok := claimTime(claims, "iat")
+	if !ok || now.Add(v.ClockSkew).Before(iat) {
+		return OAuthErrorIDTokenIssuedAt()
+	}
+
+	if len(nonce) > 0 {
+		claimNonce, _ := claims["nonce"].(string)
+		if subtle.ConstantTimeCompare([]byte(nonce), []byte(claimNonce)) != 1 {
+			return OAuthErrorIDTokenNonce()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DecxBase/core/types"
+)
+
+func signTestIDToken(t *testing.T, key *ecdsa.PrivateKey, claims types.JSONDumpData) string {
+	token, err := SignJWT(JWTAlgES256, key, types.JSONDumpData{"kid": "k1"}, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func testIDTokenProvider(t *testing.T, now time.Time) (*ecdsa.PrivateKey, *OAuthProviderBase) {
+	key, jwk := testJWK(t, "k1")
+
+	var hits atomic.Int32
+	var fail atomic.Bool
+	srv := testJWKSServer(t, &hits, &fail, jwk)
+
+	return key, &OAuthProviderBase{
+		config: Config("client", "secret",
+			WithClock(func() time.Time { return now }),
+			WithClockSkew(time.Minute),
+		),
+		keys:    JWKS(srv.URL),
+		issuers: []string{"https://idp.test"},
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	key, provider := testIDTokenProvider(t, now)
+	other, _ := testJWK(t, "k1")
+
+	at := func(offset time.Duration) int64 {
+		return now.Add(offset).Unix()
+	}
+
+	tests := []struct {
+		name   string
+		key    *ecdsa.PrivateKey
+		claims types.JSONDumpData
+		drop   string
+		nonce  string
+		reason string
+	}{
+		{name: "valid", nonce: "n1"},
+		{name: "without an expected nonce"},
+		{name: "other key", key: other, reason: "id_token_signature"},
+		{name: "issuer", claims: types.JSONDumpData{"iss": "https://evil.test"}, reason: "id_token_issuer"},
+		{name: "audience", claims: types.JSONDumpData{"aud": "other"}, reason: "id_token_audience"},
+		{name: "audiences with azp", claims: types.JSONDumpData{"aud": []string{"client", "other"}, "azp": "client"}},
+		{name: "audiences without azp", claims: types.JSONDumpData{"aud": []string{"client", "other"}}, reason: "id_token_azp"},
+		{name: "other azp", claims: types.JSONDumpData{"azp": "other"}, reason: "id_token_azp"},
+		{name: "expired", claims: types.JSONDumpData{"exp": at(-2 * time.Minute)}, reason: "id_token_expired"},
+		{name: "expired within skew", claims: types.JSONDumpData{"exp": at(-30 * time.Second)}},
+		{name: "without exp", drop: "exp", reason: "id_token_expired"},
+		{name: "not yet valid", claims: types.JSONDumpData{"nbf": at(2 * time.Minute)}, reason: "id_token_not_yet_valid"},
+		{name: "not yet valid within skew", claims: types.JSONDumpData{"nbf": at(30 * time.Second)}},
+		{name: "issued in the future", claims: types.JSONDumpData{"iat": at(2 * time.Minute)}, reason: "id_token_issued_at"},
+		{name: "without iat", drop: "iat", reason: "id_token_issued_at"},
+		{name: "other nonce", nonce: "n2", reason: "id_token_nonce"},
+		{name: "without nonce", drop: "nonce", nonce: "n1", reason: "id_token_nonce"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := types.JSONDumpData{
+				"iss":   "https://idp.test",
+				"sub":   "123",
+				"aud":   "client",
+				"exp":   at(time.Hour),
+				"iat":   at(0),
+				"nonce": "n1",
+			}
+			for key, val := range tt.claims {
+				claims[key] = val
+			}
+			delete(claims, tt.drop)
+
+			signer := key
+			if tt.key != nil {
+				signer = tt.key
+			}
+
+			_, err := provider.VerifyIDToken(signTestIDToken(t, signer, claims), tt.nonce)
+			if OAuthErrorReason(err) != tt.reason {
+				t.Fatalf("verify error = %v, want reason %q", err, tt.reason)
+			}
+		})
+	}
+}
+
+func TestIDTokenClaimsSkipsExpiry(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	key, provider := testIDTokenProvider(t, now)
+
+	token := signTestIDToken(t, key, types.JSONDumpData{
+		"iss": "https://idp.test",
+		"aud": "client",
+		"exp": now.Add(-time.Hour).Unix(),
+		"iat": now.Add(-2 * time.Hour).Unix(),
+	})
+
+	if _, err := provider.IDTokenClaims(context.Background(), token); err != nil {
+		t.Errorf("claims of an expired token: %v", err)
+	}
+
+	if _, err := provider.VerifyIDToken(token, ""); OAuthErrorReason(err) != "id_token_expired" {
+		t.Errorf("verify error = %v, want id_token_expired", err)
+	}
+}
+
+func TestCallbackRestoresNonce(t *testing.T) {
+	now := time.Now()
+	key, base := testIDTokenProvider(t, now)
+
+	tests := []struct {
+		name   string
+		nonce  func(*oAuthOptions) string
+		reason string
+	}{
+		{name: "nonce from state", nonce: func(o *oAuthOptions) string { return o.Nonce }},
+		{name: "other nonce", nonce: func(o *oAuthOptions) string { return "other" }, reason: "id_token_nonce"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := Service[string]().Register("stub", stubOAuthProvider{
+				OAuthProviderBase: base,
+				idToken: func(opts *oAuthOptions) string {
+					return signTestIDToken(t, key, types.JSONDumpData{
+						"iss":   "https://idp.test",
+						"aud":   "client",
+						"exp":   now.Add(time.Hour).Unix(),
+						"iat":   now.Unix(),
+						"nonce": tt.nonce(opts),
+					})
+				},
+			})
+
+			result, err := srv.Initialize("stub", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = srv.Callback("stub", types.JSONStringData{"code": "good", "state": result.State})
+			if OAuthErrorReason(err) != tt.reason {
+				t.Fatalf("callback error = %v, want reason %q", err, tt.reason)
+			}
+		})
+	}
+}
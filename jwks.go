@@ -0,0 +1,311 @@
+package oauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultJWKSMaxAge     = time.Hour
+	DefaultJWKSMinRefresh = time.Minute
+)
+
+type OAuthJWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k OAuthJWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := Base64URLDecode(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := Base64URLDecode(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		pub := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if pub.N.BitLen() < 2048 || pub.E < 3 {
+			return nil, errors.New("jwks: rsa key too weak")
+		}
+
+		return pub, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, errors.New("jwks: unsupported curve " + k.Crv)
+		}
+
+		x, err := Base64URLDecode(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := Base64URLDecode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("jwks: invalid ec point")
+		}
+
+		// ecdh rejects points which aren't on the curve
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, errors.New("jwks: unsupported curve " + k.Crv)
+		}
+
+		x, err := Base64URLDecode(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("jwks: invalid ed25519 key")
+		}
+
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, errors.New("jwks: unsupported key type " + k.Kty)
+}
+
+func (k OAuthJWK) Accepts(alg string) bool {
+	if len(k.Alg) > 0 {
+		return k.Alg == alg
+	}
+
+	switch k.Kty {
+	case "RSA":
+		return alg == JWTAlgRS256
+	case "EC":
+		return alg == JWTAlgES256
+	case "OKP":
+		return alg == JWTAlgEdDSA
+	}
+
+	return false
+}
+
+type oAuthKeyEntry struct {
+	jwk OAuthJWK
+	key crypto.PublicKey
+}
+
+type oAuthKeyFetch struct {
+	done chan struct{}
+	err  error
+}
+
+type oAuthKeySet struct {
+	uri        string
+	maxAge     time.Duration
+	minRefresh time.Duration
+
+	mu          sync.Mutex
+	keys        []oAuthKeyEntry
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	err         error
+	flight      *oAuthKeyFetch
+}
+
+func fetchKeys(ctx context.Context, raw string) ([]oAuthKeyEntry, error) {
+	uri, err := ParseURI(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := OAuthRequestContext(ctx, uri, RequestOptions(
+		WithReqOptRetryPolicy(DefaultRetryPolicy()),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	var data struct {
+		Keys []OAuthJWK `json:"keys"`
+	}
+	if err := json.Unmarshal(res, &data); err != nil {
+		return nil, OAuthErrorDecodeFailed()
+	}
+
+	keys := make([]oAuthKeyEntry, 0, len(data.Keys))
+	for _, jwk := range data.Keys {
+		if len(jwk.Use) > 0 && jwk.Use != "sig" {
+			continue
+		}
+
+		// keys we can't understand are skipped, the others may still verify
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+
+		keys = append(keys, oAuthKeyEntry{jwk: jwk, key: key})
+	}
+
+	return keys, nil
+}
+
+// canRefresh must be called with the lock held, after any attempt, failed ones included,
+// the endpoint is left alone for minRefresh
+func (s *oAuthKeySet) canRefresh() bool {
+	return s.flight != nil || s.attemptedAt.IsZero() || time.Since(s.attemptedAt) > s.minRefresh
+}
+
+// refresh must be called with the lock held, which it releases while waiting for the
+// fetch, concurrent callers share a single one
+func (s *oAuthKeySet) refresh(ctx context.Context) error {
+	call := s.flight
+	if call == nil {
+		call = &oAuthKeyFetch{
+			done: make(chan struct{}),
+		}
+		s.flight = call
+		s.attemptedAt = time.Now()
+
+		// the fetch outlives any single waiter, so one cancelled caller doesn't fail the rest
+		go s.runFetch(context.WithoutCancel(ctx), call, s.uri)
+	}
+
+	s.mu.Unlock()
+	defer s.mu.Lock()
+
+	select {
+	case <-call.done:
+		return call.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *oAuthKeySet) runFetch(ctx context.Context, call *oAuthKeyFetch, uri string) {
+	keys, err := fetchKeys(ctx, uri)
+
+	s.mu.Lock()
+	if s.flight == call {
+		if err == nil {
+			s.keys = keys
+			s.fetchedAt = time.Now()
+		}
+		s.err = err
+		s.flight = nil
+	}
+	s.mu.Unlock()
+
+	call.err = err
+	close(call.done)
+}
+
+func (s *oAuthKeySet) lookup(kid string, alg string) []crypto.PublicKey {
+	found := make([]crypto.PublicKey, 0)
+
+	for _, entry := range s.keys {
+		if (len(kid) < 1 || entry.jwk.Kid == kid) && entry.jwk.Accepts(alg) {
+			found = append(found, entry.key)
+		}
+	}
+
+	return found
+}
+
+func (s *oAuthKeySet) Keys(ctx context.Context, kid string, alg string) ([]crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fetchedAt.IsZero() || time.Since(s.fetchedAt) > s.maxAge {
+		err := s.err
+		if s.canRefresh() {
+			err = s.refresh(ctx)
+		}
+
+		// a failed refetch keeps serving the cached keys, they are rarely all retired at once
+		if err != nil && len(s.keys) < 1 {
+			return nil, err
+		}
+	}
+
+	keys := s.lookup(kid, alg)
+
+	// an unknown kid usually means the provider rotated its keys
+	if len(keys) < 1 && s.canRefresh() {
+		if err := s.refresh(ctx); err != nil {
+			return nil, err
+		}
+		keys = s.lookup(kid, alg)
+	}
+
+	return keys, nil
+}
+
+func (s *oAuthKeySet) SetURI(uri string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.uri != uri {
+		s.uri = uri
+		s.keys = nil
+		s.fetchedAt = time.Time{}
+		s.attemptedAt = time.Time{}
+		s.err = nil
+		s.flight = nil
+	}
+}
+
+func (s *oAuthKeySet) Verify(ctx context.Context, token *OAuthJWT) error {
+	keys, err := s.Keys(ctx, token.KeyID(), token.Algorithm())
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if VerifyJWTSignature(token.Algorithm(), key, token.Signed, token.Signature) == nil {
+			return nil
+		}
+	}
+
+	return OAuthErrorIDTokenSignature()
+}
+
+func JWKS(uri string) *oAuthKeySet {
+	return &oAuthKeySet{
+		uri:        uri,
+		maxAge:     DefaultJWKSMaxAge,
+		minRefresh: DefaultJWKSMinRefresh,
+	}
+}
@@ -0,0 +1,155 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testJWK(t *testing.T, kid string) (*ecdsa.PrivateKey, OAuthJWK) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, OAuthJWK{
+		Kty: "EC",
+		Kid: kid,
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+// testJWKSServer serves `keys` until `fail` is set, counting every request
+func testJWKSServer(t *testing.T, hits *atomic.Int32, fail *atomic.Bool, keys ...OAuthJWK) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if fail.Load() {
+			http.NotFound(w, r)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestKeySetRefetchFailure(t *testing.T) {
+	ctx := context.Background()
+	_, jwk := testJWK(t, "k1")
+
+	var hits atomic.Int32
+	var fail atomic.Bool
+	set := JWKS(testJWKSServer(t, &hits, &fail, jwk).URL)
+
+	if keys, err := set.Keys(ctx, "k1", JWTAlgES256); err != nil || len(keys) != 1 {
+		t.Fatalf("keys = %d, %v", len(keys), err)
+	}
+
+	// past maxAge with the endpoint down, the cached keys keep verifying
+	fail.Store(true)
+	set.maxAge, set.minRefresh = 0, 0
+	time.Sleep(time.Millisecond)
+
+	if keys, err := set.Keys(ctx, "k1", JWTAlgES256); err != nil || len(keys) != 1 {
+		t.Fatalf("keys after a failed refetch = %d, %v", len(keys), err)
+	}
+
+	if hits.Load() != 2 {
+		t.Fatalf("hits = %d, want 2", hits.Load())
+	}
+
+	// the failed attempt backs off like a successful one
+	set.minRefresh = time.Hour
+	for range 3 {
+		if keys, err := set.Keys(ctx, "k9", JWTAlgES256); err != nil || len(keys) != 0 {
+			t.Fatalf("unknown kid = %d, %v", len(keys), err)
+		}
+	}
+
+	if hits.Load() != 2 {
+		t.Errorf("hits while backing off = %d, want 2", hits.Load())
+	}
+}
+
+func TestKeySetInitialFailure(t *testing.T) {
+	ctx := context.Background()
+
+	var hits atomic.Int32
+	var fail atomic.Bool
+	fail.Store(true)
+	set := JWKS(testJWKSServer(t, &hits, &fail).URL)
+
+	for range 3 {
+		if _, err := set.Keys(ctx, "k1", JWTAlgES256); err == nil {
+			t.Fatal("keys without a successful fetch")
+		}
+	}
+
+	if hits.Load() != 1 {
+		t.Errorf("hits = %d, want 1", hits.Load())
+	}
+}
+
+func TestKeySetSingleFetch(t *testing.T) {
+	_, jwk := testJWK(t, "k1")
+
+	var hits atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+		json.NewEncoder(w).Encode(map[string]any{"keys": []OAuthJWK{jwk}})
+	}))
+	t.Cleanup(srv.Close)
+
+	set := JWKS(srv.URL)
+
+	// a caller giving up doesn't hold anyone else up nor cancel the shared fetch
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := set.Keys(ctx, "k1", JWTAlgES256); err == nil {
+		t.Fatal("keys before the fetch finished")
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			keys, err := set.Keys(context.Background(), "k1", JWTAlgES256)
+			if err == nil && len(keys) != 1 {
+				err = OAuthErrorIDTokenSignature()
+			}
+			errs <- err
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if hits.Load() != 1 {
+		t.Errorf("hits = %d, want 1", hits.Load())
+	}
+}
@@ -0,0 +1,174 @@
+package oauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+
+	"github.com/DecxBase/core/types"
+)
+
+const (
+	JWTAlgRS256 = "RS256"
+	JWTAlgES256 = "ES256"
+	JWTAlgEdDSA = "EdDSA"
+)
+
+type OAuthJWT struct {
+	Header    types.JSONDumpData
+	Claims    types.JSONDumpData
+	Signed    []byte
+	Signature []byte
+}
+
+func (t OAuthJWT) headerString(key string) string {
+	val, _ := t.Header[key].(string)
+	return val
+}
+
+func (t OAuthJWT) Algorithm() string {
+	return t.headerString("alg")
+}
+
+func (t OAuthJWT) KeyID() string {
+	return t.headerString("kid")
+}
+
+func Base64URLDecode(str string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(str, "="))
+}
+
+func ParseJWT(token string) (*OAuthJWT, error) {
+	segments := strings.Split(token, ".")
+	if len(segments) != 3 {
+		return nil, OAuthErrorJWTFailed()
+	}
+
+	result := &OAuthJWT{
+		Signed: []byte(segments[0] + "." + segments[1]),
+	}
+
+	for idx, target := range []*types.JSONDumpData{&result.Header, &result.Claims} {
+		dataBytes, err := Base64URLDecode(segments[idx])
+		if err != nil {
+			return nil, OAuthErrorJWTFailed()
+		}
+
+		if err := json.Unmarshal(dataBytes, target); err != nil {
+			return nil, OAuthErrorDecodeFailed()
+		}
+	}
+
+	signature, err := Base64URLDecode(segments[2])
+	if err != nil {
+		return nil, OAuthErrorJWTFailed()
+	}
+	result.Signature = signature
+
+	return result, nil
+}
+
+func VerifyJWTSignature(alg string, key crypto.PublicKey, signed []byte, signature []byte) error {
+	switch alg {
+	case JWTAlgRS256:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return OAuthErrorIDTokenSignature()
+		}
+
+		digest := sha256.Sum256(signed)
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature) != nil {
+			return OAuthErrorIDTokenSignature()
+		}
+	case JWTAlgES256:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || pub.Curve.Params().BitSize != 256 || len(signature) != 64 {
+			return OAuthErrorIDTokenSignature()
+		}
+
+		digest := sha256.Sum256(signed)
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return OAuthErrorIDTokenSignature()
+		}
+	case JWTAlgEdDSA:
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(pub, signed, signature) {
+			return OAuthErrorIDTokenSignature()
+		}
+	default:
+		return OAuthErrorIDTokenSignature()
+	}
+
+	return nil
+}
+
+// SignJWT builds a compact JWT, `key` must match `alg`: *ecdsa.PrivateKey on P-256 for
+// ES256, *rsa.PrivateKey for RS256 and ed25519.PrivateKey for EdDSA
+func SignJWT(alg string, key crypto.Signer, header types.JSONDumpData, claims types.JSONDumpData) (string, error) {
+	full := types.JSONDumpData{"alg": alg, "typ": "JWT"}
+	for hkey, hval := range header {
+		full[hkey] = hval
+	}
+
+	segments := make([]string, 0, 3)
+	for _, part := range []types.JSONDumpData{full, claims} {
+		data, err := json.Marshal(part)
+		if err != nil {
+			return "", err
+		}
+		segments = append(segments, base64.RawURLEncoding.EncodeToString(data))
+	}
+
+	signed := []byte(strings.Join(segments, "."))
+
+	var signature []byte
+	switch alg {
+	case JWTAlgRS256:
+		priv, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return "", OAuthErrorJWTFailed()
+		}
+
+		digest := sha256.Sum256(signed)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, digest[:])
+		if err != nil {
+			return "", err
+		}
+		signature = sig
+	case JWTAlgES256:
+		priv, ok := key.(*ecdsa.PrivateKey)
+		if !ok || priv.Curve.Params().BitSize != 256 {
+			return "", OAuthErrorJWTFailed()
+		}
+
+		digest := sha256.Sum256(signed)
+		r, s, err := ecdsa.Sign(rand.Reader, priv, digest[:])
+		if err != nil {
+			return "", err
+		}
+
+		// JWS wants the fixed size r || s form rather than ASN.1
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	case JWTAlgEdDSA:
+		priv, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return "", OAuthErrorJWTFailed()
+		}
+		signature = ed25519.Sign(priv, signed)
+	default:
+		return "", OAuthErrorJWTFailed()
+	}
+
+	return string(signed) + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,56 @@
+package oauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/DecxBase/core/types"
+)
+
+func TestJWTES256RoundTrip(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := SignJWT(JWTAlgES256, key, types.JSONDumpData{"kid": "k1"}, types.JSONDumpData{"sub": "123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseJWT(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.Algorithm() != JWTAlgES256 || parsed.KeyID() != "k1" || parsed.Claims["sub"] != "123" {
+		t.Fatalf("unexpected token %+v", parsed)
+	}
+
+	tests := []struct {
+		name   string
+		key    *ecdsa.PublicKey
+		signed []byte
+		valid  bool
+	}{
+		{"valid", &key.PublicKey, parsed.Signed, true},
+		{"wrong key", &other.PublicKey, parsed.Signed, false},
+		{"tampered", &key.PublicKey, append([]byte("x"), parsed.Signed...), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyJWTSignature(JWTAlgES256, tt.key, tt.signed, parsed.Signature)
+			if (err == nil) != tt.valid {
+				t.Errorf("verify error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package oauth
+
+import (
+	"fmt"
+	"strings"
+)
+
+const sealedPrefix = "enc:v1:"
+
+type oAuthKeyring struct {
+	current string
+	keys    map[string][]byte
+}
+
+func (k oAuthKeyring) Current() string {
+	return k.current
+}
+
+func (k oAuthKeyring) Has(kid string) bool {
+	_, ok := k.keys[kid]
+	return ok
+}
+
+// Seal encrypts with the current key, the result reads `enc:v1:<kid>:<data>`
+func (k oAuthKeyring) Seal(plaintext []byte, additional []byte) (string, error) {
+	sealed, err := sealData(k.keys[k.current], plaintext, additional)
+	if err != nil {
+		return "", err
+	}
+
+	return sealedPrefix + k.current + ":" + sealed, nil
+}
+
+func (k oAuthKeyring) Open(value string, additional []byte) ([]byte, error) {
+	kid, data, ok := splitSealed(value)
+	if !ok {
+		return nil, OAuthErrorDecryptFailed()
+	}
+
+	key, found := k.keys[kid]
+	if !found {
+		return nil, OAuthErrorUnknownKey(kid)
+	}
+
+	plaintext, err := openData(key, data, additional)
+	if err != nil {
+		return nil, OAuthErrorDecryptFailed()
+	}
+
+	return plaintext, nil
+}
+
+func isSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
+
+func splitSealed(value string) (string, string, bool) {
+	if !isSealed(value) {
+		return "", "", false
+	}
+
+	return strings.Cut(strings.TrimPrefix(value, sealedPrefix), ":")
+}
+
+// Keyring holds every key which may still be found on stored data, new data is
+// always sealed with `current`. Rotate by adding a key and making it current,
+// then drop the old one once re-encryption has finished.
+func Keyring(current string, secrets map[string][]byte) (*oAuthKeyring, error) {
+	keyring := &oAuthKeyring{
+		current: current,
+		keys:    make(map[string][]byte, len(secrets)),
+	}
+
+	for kid, secret := range secrets {
+		if len(kid) < 1 || strings.Contains(kid, ":") {
+			return nil, fmt.Errorf("oauth: invalid key id %q", kid)
+		}
+
+		if len(secret) < 16 {
+			return nil, fmt.Errorf("oauth: key %q is too short", kid)
+		}
+
+		keyring.keys[kid] = deriveKey(secret)
+	}
+
+	if !keyring.Has(current) {
+		return nil, OAuthErrorUnknownKey(current)
+	}
+
+	return keyring, nil
+}
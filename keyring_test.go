@@ -0,0 +1,84 @@
+package oauth
+
+import (
+	"context"
+	"testing"
+)
+
+func TestKeyringSealOpen(t *testing.T) {
+	old, err := Keyring("k1", map[string][]byte{
+		"k1": []byte("0123456789abcdef-k1"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := Keyring("k2", map[string][]byte{
+		"k1": []byte("0123456789abcdef-k1"),
+		"k2": []byte("0123456789abcdef-k2"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := old.Seal([]byte("secret"), []byte("aad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		keyring    *oAuthKeyring
+		value      string
+		additional string
+		reason     string
+	}{
+		{"same key", old, sealed, "aad", ""},
+		{"rotated keyring", rotated, sealed, "aad", ""},
+		{"other additional data", old, sealed, "other", "encryption"},
+		{"plaintext", old, "secret", "aad", "encryption"},
+		{"unknown key", old, sealedPrefix + "k9:AAAA", "aad", "encryption"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.keyring.Open(tt.value, []byte(tt.additional))
+			if OAuthErrorReason(err) != tt.reason {
+				t.Fatalf("open error = %v, want reason %q", err, tt.reason)
+			}
+
+			if err == nil && string(data) != "secret" {
+				t.Errorf("opened %q", data)
+			}
+		})
+	}
+}
+
+func TestEnvelopeTokenStorePlaintext(t *testing.T) {
+	ctx := context.Background()
+	key := OAuthTokenKey{Provider: "p", UserID: "1"}
+
+	keyring, err := Keyring("k1", map[string][]byte{"k1": []byte("0123456789abcdef-k1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backing := MemoryTokenStore()
+	if err := backing.Save(ctx, &OAuthTokenRecord{OAuthTokenKey: key, Token: &OAuthToken{AccessToken: "planted"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := EnvelopeTokenStore(backing, keyring).Load(ctx, key); OAuthErrorReason(err) != "encryption" {
+		t.Fatalf("plaintext record loaded, error = %v", err)
+	}
+
+	migrate := EnvelopeTokenStore(backing, keyring, WithEnvelopePlaintext())
+	if count, err := migrate.Reencrypt(ctx, "p"); err != nil || count != 1 {
+		t.Fatalf("reencrypt = %d, %v", count, err)
+	}
+
+	record, err := EnvelopeTokenStore(backing, keyring).Load(ctx, key)
+	if err != nil || record.Token.AccessToken != "planted" {
+		t.Fatalf("load after reencrypt = %+v, %v", record, err)
+	}
+}
@@ -0,0 +1,349 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/DecxBase/core/types"
+	"github.com/DecxBase/core/utils"
+)
+
+const (
+	MicrosoftTenantCommon        = "common"
+	MicrosoftTenantOrganizations = "organizations"
+	MicrosoftTenantConsumers     = "consumers"
+
+	// personal Microsoft accounts all sign in through this tenant
+	microsoftConsumersTenantID = "9188040d-6c67-4c5b-b112-36a304b66dad"
+)
+
+type microsoftOAuthProvider struct {
+	*OAuthProviderBase
+	tenant   string
+	resolved *microsoftTenantID
+}
+
+// microsoftTenantID caches the tenant ID a verified domain resolved to
+type microsoftTenantID struct {
+	mu sync.Mutex
+	id string
+}
+
+func (p microsoftOAuthProvider) Name() string {
+	return "microsoft"
+}
+
+func (p microsoftOAuthProvider) FieldMappings() utils.DataMap[string] {
+	return utils.MakeDataMap(map[string]string{
+		"full_name": "displayName",
+		"email":     "mail",
+	})
+}
+
+func (p microsoftOAuthProvider) endpoint(to string) *oAuthURI {
+	return p.client.Clone().SetPath(fmt.Sprintf("%s/oauth2/v2.0/%s", p.tenant, to))
+}
+
+func (p microsoftOAuthProvider) Initialize(opts *oAuthOptions, scopes ...string) (*OAuthRequestResult, error) {
+	return p.InitializeContext(context.Background(), opts, scopes...)
+}
+
+func (p microsoftOAuthProvider) InitializeContext(ctx context.Context, opts *oAuthOptions, scopes ...string) (*OAuthRequestResult, error) {
+	rScopes := ResolveScopes(
+		[]string{
+			"openid",
+			"offline_access",
+		},
+		scopes,
+		"profile",
+		"email",
+	)
+
+	uri := p.endpoint("authorize").
+		Set("client_id", p.config.ClientID()).
+		Set("response_type", "code").
+		Set("response_mode", "query").
+		Set("scope", strings.Join(rScopes, " ")).
+		Set("redirect_uri", opts.Redirect).
+		SetIf(len(opts.State) > 0, "state", opts.State).
+		SetIf(len(opts.Nonce) > 0, "nonce", opts.Nonce).
+		SetIf(len(opts.Prompt) > 0, "prompt", opts.Prompt).
+		SetIf(opts.MaxAge > 0, "max_age", opts.maxAgeSeconds()).
+		SetIf(len(opts.CodeChallenge) > 0, "code_challenge", opts.CodeChallenge).
+		SetIf(len(opts.CodeChallenge) > 0, "code_challenge_method", opts.CodeChallengeMethod)
+
+	return &OAuthRequestResult{
+		Type: OAuthRequestRedirect,
+		Data: uri.String(),
+	}, nil
+}
+
+func (p microsoftOAuthProvider) tokenRequest(ctx context.Context, cb OAuthRawCallback) (*OAuthToken, error) {
+	data, err := p.RunTokenRequestContext(ctx, func(uri *oAuthURI) *oAuthURI {
+		return cb(p.endpoint("token")).
+			SetBody("client_id", p.config.ClientID()).
+			SetBody("client_secret", p.config.ClientSecret())
+	}, RequestOptions(
+		WithReqOptMethod(http.MethodPost),
+		WithReqOptForm(),
+		// refresh tokens rotate, a replayed request would burn the new one
+		WithReqOptRetry(RetryConnectOnly),
+	), "access_token")
+
+	if err != nil {
+		return nil, err
+	}
+	return p.MakeTokenResult(data)
+}
+
+func (p microsoftOAuthProvider) Callback(opts *oAuthOptions) (*OAuthToken, error) {
+	return p.CallbackContext(context.Background(), opts)
+}
+
+func (p microsoftOAuthProvider) CallbackContext(ctx context.Context, opts *oAuthOptions) (*OAuthToken, error) {
+	code, err := opts.GetConfigString("code")
+	if err != nil {
+		return nil, err
+	}
+
+	return p.tokenRequest(ctx, func(uri *oAuthURI) *oAuthURI {
+		return uri.SetBody("grant_type", "authorization_code").
+			SetBody("code", code).
+			SetBody("redirect_uri", opts.Redirect).
+			SetBodyIf(len(opts.CodeVerifier) > 0, "code_verifier", opts.CodeVerifier)
+	})
+}
+
+func (p microsoftOAuthProvider) RefreshToken(token string) (*OAuthToken, error) {
+	return p.RefreshTokenContext(context.Background(), token)
+}
+
+func (p microsoftOAuthProvider) RefreshTokenContext(ctx context.Context, token string) (*OAuthToken, error) {
+	return p.tokenRequest(ctx, func(uri *oAuthURI) *oAuthURI {
+		return uri.SetBody("grant_type", "refresh_token").
+			SetBody("refresh_token", token)
+	})
+}
+
+// tenantID resolves a verified domain through its discovery document, whose issuer
+// names the tenant ID tokens of that tenant carry as `tid`
+func (p microsoftOAuthProvider) tenantID(ctx context.Context) (string, error) {
+	if isTenantID(p.tenant) {
+		return p.tenant, nil
+	}
+
+	p.resolved.mu.Lock()
+	defer p.resolved.mu.Unlock()
+
+	if len(p.resolved.id) > 0 {
+		return p.resolved.id, nil
+	}
+
+	res, err := OAuthDoRequestContext(p.withClient(ctx), p.client.Clone().SetPath(
+		fmt.Sprintf("%s/v2.0/.well-known/openid-configuration", p.tenant),
+	), RequestOptions(
+		WithReqHeader("Accept", "application/json"),
+		WithReqOptRetryPolicy(DefaultRetryPolicy()),
+	))
+	if err != nil {
+		return "", err
+	}
+
+	var discovery OIDCDiscovery
+	if err := json.Unmarshal(res.Body, &discovery); err != nil {
+		return "", OAuthErrorDecodeFailed()
+	}
+
+	tid, _ := strings.CutPrefix(discovery.Issuer, fmt.Sprintf("https://%s/", p.client.Host))
+	tid, ok := strings.CutSuffix(tid, "/v2.0")
+	if !ok || !isTenantID(tid) {
+		return "", OAuthError{
+			Reason:  "discovery",
+			Message: fmt.Sprintf("Tenant [%s] didn't resolve to a tenant ID", p.tenant),
+		}
+	}
+
+	p.resolved.id = tid
+	return tid, nil
+}
+
+// checkTenant matches the token's `tid` against the configured tenant and allowlist,
+// and returns the only issuer a token from that tenant may carry
+func (p microsoftOAuthProvider) checkTenant(ctx context.Context, claims types.JSONDumpData) (string, error) {
+	tid, _ := claims["tid"].(string)
+	if len(tid) < 1 {
+		return "", OAuthErrorIDTokenIssuer()
+	}
+
+	switch p.tenant {
+	case MicrosoftTenantCommon:
+	case MicrosoftTenantOrganizations:
+		if tid == microsoftConsumersTenantID {
+			return "", OAuthErrorIDTokenIssuer()
+		}
+	case MicrosoftTenantConsumers:
+		if tid != microsoftConsumersTenantID {
+			return "", OAuthErrorIDTokenIssuer()
+		}
+	default:
+		// a verified domain can stand in for the tenant id, tokens still carry the id
+		expected, err := p.tenantID(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		if !strings.EqualFold(tid, expected) {
+			return "", OAuthErrorIDTokenIssuer()
+		}
+	}
+
+	if tenants, ok := p.config.GetExtra(ConfigTenants).([]string); ok && len(tenants) > 0 {
+		allowed := false
+		for _, tenant := range tenants {
+			allowed = allowed || strings.EqualFold(tenant, tid)
+		}
+
+		if !allowed {
+			return "", OAuthErrorIDTokenIssuer()
+		}
+	}
+
+	return fmt.Sprintf("https://%s/%s/v2.0", p.client.Host, tid), nil
+}
+
+// microsoftEmailVerified reads the optional `xms_edov` claim, which has to be requested
+// in the app registration
+func microsoftEmailVerified(claims types.JSONDumpData) bool {
+	switch edov := claims["xms_edov"].(type) {
+	case bool:
+		return edov
+	case string:
+		return edov == "true" || edov == "1"
+	case float64:
+		return edov == 1
+	}
+
+	return false
+}
+
+func isTenantID(tenant string) bool {
+	return len(tenant) == 36 && strings.Count(tenant, "-") == 4
+}
+
+func (p microsoftOAuthProvider) VerifyIDToken(token string, nonce string) (types.JSONDumpData, error) {
+	return p.VerifyIDTokenContext(context.Background(), token, nonce)
+}
+
+func (p microsoftOAuthProvider) VerifyIDTokenContext(ctx context.Context, token string, nonce string) (types.JSONDumpData, error) {
+	return p.verifyIDToken(ctx, token, nonce, p.IDTokenValidator())
+}
+
+func (p microsoftOAuthProvider) IDTokenClaims(ctx context.Context, token string) (types.JSONDumpData, error) {
+	validator := p.IDTokenValidator()
+	validator.SkipExpiry = true
+
+	return p.verifyIDToken(ctx, token, "", validator)
+}
+
+// verifyIDToken pins the issuer to the token's tenant once the tenant passed checkTenant
+func (p microsoftOAuthProvider) verifyIDToken(ctx context.Context, token string, nonce string, validator IDTokenValidator) (types.JSONDumpData, error) {
+	parsed, err := ParseJWT(token)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.VerifyIDTokenSignature(ctx, parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	issuer, err := p.checkTenant(ctx, parsed.Claims)
+	if err != nil {
+		return nil, err
+	}
+
+	validator.Issuers = []string{issuer}
+
+	err = validator.Validate(parsed.Claims, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsed.Claims, nil
+}
+
+func (p microsoftOAuthProvider) TokenToUser(token *OAuthToken) (*OAuthUser, error) {
+	return p.TokenToUserContext(context.Background(), token)
+}
+
+func (p microsoftOAuthProvider) TokenToUserContext(ctx context.Context, token *OAuthToken) (*OAuthUser, error) {
+	claims, err := p.IDTokenClaims(ctx, token.IDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	tid, _ := claims["tid"].(string)
+	oid, _ := claims["oid"].(string)
+	if len(tid) < 1 || len(oid) < 1 {
+		return nil, OAuthErrorDecodeFailed()
+	}
+
+	// oid alone isn't stable across tenants, Microsoft keys users on both
+	user := &OAuthUser{
+		UserID:       tid + ":" + oid,
+		IdentityType: "username",
+		AccessToken:  token.AccessToken,
+		ExpiresIn:    token.ExpiresIn,
+		Profile:      claims,
+	}
+	user.Identity, _ = claims["preferred_username"].(string)
+
+	// any tenant admin can set `email` to anything, it's only trusted once the domain
+	// owner is verified, otherwise accounts could be taken over by address (nOAuth)
+	if email, ok := claims["email"].(string); ok && len(email) > 0 && microsoftEmailVerified(claims) {
+		user.IdentityType = "email"
+		user.Identity = email
+	}
+
+	return user, nil
+}
+
+func (p microsoftOAuthProvider) Get(token string, fields []string, opts *oAuthOptions) (types.JSONDumpData, error) {
+	return p.GetContext(context.Background(), token, fields, opts)
+}
+
+func (p microsoftOAuthProvider) GetContext(ctx context.Context, token string, fields []string, opts *oAuthOptions) (types.JSONDumpData, error) {
+	path := "v1.0/me"
+	if len(opts.RequestPath) > 0 {
+		path = opts.RequestPath
+	}
+
+	return p.CallContext(ctx, func(uri *oAuthURI) *oAuthURI {
+		return URIHost("graph.microsoft.com").SetPath(path).
+			SetIf(len(fields) > 0, "$select", strings.Join(fields, ","))
+	}, RequestOptions(
+		WithReqHeader("Authorization", fmt.Sprintf("Bearer %s", token)),
+	))
+}
+
+// MicrosoftOAuth signs users in through Microsoft Entra ID, `tenant` is one of the
+// MicrosoftTenant constants, a tenant ID or a verified domain
+func MicrosoftOAuth(config OAuthConfig, tenant string, cbs ...OAuthProviderCallback) *microsoftOAuthProvider {
+	if len(tenant) < 1 {
+		tenant = MicrosoftTenantCommon
+	}
+
+	return &microsoftOAuthProvider{
+		tenant:   tenant,
+		resolved: &microsoftTenantID{},
+		OAuthProviderBase: (&OAuthProviderBase{
+			config: config,
+			client: URIHost("login.microsoftonline.com"),
+			keys:   JWKS(fmt.Sprintf("https://login.microsoftonline.com/%s/discovery/v2.0/keys", tenant)),
+		}).apply(cbs),
+	}
+}
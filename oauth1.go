@@ -0,0 +1,262 @@
+package oauth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	OAuth1HMACSHA1  = "HMAC-SHA1"
+	OAuth1RSASHA1   = "RSA-SHA1"
+	OAuth1Plaintext = "PLAINTEXT"
+)
+
+type OAuth1Signer struct {
+	ConsumerKey    string
+	ConsumerSecret string
+	Method         string
+	PrivateKey     *rsa.PrivateKey
+	Now            func() time.Time
+}
+
+// oAuth1Escape is RFC 3986 percent encoding, which differs from url.QueryEscape for spaces and a few marks
+func oAuth1Escape(val string) string {
+	var buf strings.Builder
+	for _, b := range []byte(val) {
+		if ('A' <= b && b <= 'Z') || ('a' <= b && b <= 'z') || ('0' <= b && b <= '9') || b == '-' || b == '.' || b == '_' || b == '~' {
+			buf.WriteByte(b)
+		} else {
+			fmt.Fprintf(&buf, "%%%02X", b)
+		}
+	}
+
+	return buf.String()
+}
+
+func oAuth1BaseURL(u *url.URL) string {
+	scheme, host := strings.ToLower(u.Scheme), strings.ToLower(u.Host)
+	if (scheme == "http" && strings.HasSuffix(host, ":80")) || (scheme == "https" && strings.HasSuffix(host, ":443")) {
+		host = host[:strings.LastIndex(host, ":")]
+	}
+
+	path := u.EscapedPath()
+	if len(path) < 1 {
+		path = "/"
+	}
+
+	return scheme + "://" + host + path
+}
+
+// formParams reads a form encoded body, which takes part in the signature, and puts it back
+func oAuth1FormParams(req *http.Request) (url.Values, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType != "application/x-www-form-urlencoded" {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	return url.ParseQuery(string(body))
+}
+
+func (s OAuth1Signer) signature(base string, tokenSecret string) (string, error) {
+	key := oAuth1Escape(s.ConsumerSecret) + "&" + oAuth1Escape(tokenSecret)
+
+	switch s.Method {
+	case OAuth1HMACSHA1, "":
+		mac := hmac.New(sha1.New, []byte(key))
+		mac.Write([]byte(base))
+		return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+	case OAuth1RSASHA1:
+		if s.PrivateKey == nil {
+			return "", OAuthErrorInvalidConfig(ConfigPrivateKey)
+		}
+
+		digest := sha1.Sum([]byte(base))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, s.PrivateKey, crypto.SHA1, digest[:])
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(sig), nil
+	case OAuth1Plaintext:
+		return key, nil
+	}
+
+	return "", OAuthErrorInvalidConfig("signature_method")
+}
+
+// oAuth1BaseString builds the signature base string of RFC 5849 section 3.4.1 from the
+// request's query, its form body and the protocol parameters
+func oAuth1BaseString(req *http.Request, protocol map[string]string) (string, error) {
+	form, err := oAuth1FormParams(req)
+	if err != nil {
+		return "", err
+	}
+
+	pairs := make([][2]string, 0)
+	add := func(key string, val string) {
+		pairs = append(pairs, [2]string{oAuth1Escape(key), oAuth1Escape(val)})
+	}
+
+	for key, vals := range req.URL.Query() {
+		for _, val := range vals {
+			add(key, val)
+		}
+	}
+
+	for key, vals := range form {
+		for _, val := range vals {
+			add(key, val)
+		}
+	}
+
+	for key, val := range protocol {
+		add(key, val)
+	}
+
+	// encoded names first and values second, sorting the joined pairs would put `a1` before `a`
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+
+	joined := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		joined = append(joined, pair[0]+"="+pair[1])
+	}
+
+	return strings.Join([]string{
+		strings.ToUpper(req.Method),
+		oAuth1Escape(oAuth1BaseURL(req.URL)),
+		oAuth1Escape(strings.Join(joined, "&")),
+	}, "&"), nil
+}
+
+// Sign adds the Authorization header of RFC 5849, `extra` carries protocol parameters
+// such as oauth_callback or oauth_verifier
+func (s OAuth1Signer) Sign(req *http.Request, token string, tokenSecret string, extra map[string]string) error {
+	nonce, err := RandomString(24)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+
+	method := s.Method
+	if len(method) < 1 {
+		method = OAuth1HMACSHA1
+	}
+
+	protocol := map[string]string{
+		"oauth_consumer_key":     s.ConsumerKey,
+		"oauth_nonce":            nonce,
+		"oauth_signature_method": method,
+		"oauth_timestamp":        strconv.FormatInt(now().Unix(), 10),
+		"oauth_version":          "1.0",
+	}
+
+	if len(token) > 0 {
+		protocol["oauth_token"] = token
+	}
+
+	for key, val := range extra {
+		protocol[key] = val
+	}
+
+	base, err := oAuth1BaseString(req, protocol)
+	if err != nil {
+		return err
+	}
+
+	protocol["oauth_signature"], err = s.signature(base, tokenSecret)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(protocol))
+	for key := range protocol {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	header := make([]string, 0, len(keys))
+	for _, key := range keys {
+		header = append(header, fmt.Sprintf(`%s="%s"`, oAuth1Escape(key), oAuth1Escape(protocol[key])))
+	}
+
+	req.Header.Set("Authorization", "OAuth "+strings.Join(header, ", "))
+	return nil
+}
+
+type oAuth1Transport struct {
+	signer OAuth1Signer
+	token  string
+	secret string
+	extra  map[string]string
+	base   http.RoundTripper
+}
+
+func (t *oAuth1Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// every attempt needs a fresh nonce and timestamp, so sign a copy each time
+	clone := req.Clone(req.Context())
+	if err := t.signer.Sign(clone, t.token, t.secret, t.extra); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	return t.base.RoundTrip(clone)
+}
+
+// OAuth1Transport signs every request with the given token credentials, leave `token`
+// empty for requests made with the consumer credentials alone
+func OAuth1Transport(signer OAuth1Signer, token string, secret string, base http.RoundTripper) http.RoundTripper {
+	return oAuth1TransportExtra(signer, token, secret, nil, base)
+}
+
+func oAuth1TransportExtra(signer OAuth1Signer, token string, secret string, extra map[string]string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &oAuth1Transport{
+		signer: signer,
+		token:  token,
+		secret: secret,
+		extra:  extra,
+		base:   base,
+	}
+}
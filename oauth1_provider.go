@@ -0,0 +1,315 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/DecxBase/core/types"
+)
+
+type OAuth1Endpoints struct {
+	RequestToken string
+	Authorize    string
+	AccessToken  string
+	// Profile is fetched by TokenToUser, leave it empty to rely on the access token response
+	Profile string
+}
+
+type oauth1OAuthProvider struct {
+	*OAuthProviderBase
+	name      string
+	endpoints OAuth1Endpoints
+}
+
+func (p oauth1OAuthProvider) Name() string {
+	return p.name
+}
+
+// PKCEMethod is empty, OAuth 1.0a has no PKCE
+func (p oauth1OAuthProvider) PKCEMethod() string {
+	return ""
+}
+
+// StateKey is the request token, the callback returns it in place of a state parameter
+func (p oauth1OAuthProvider) StateKey(data map[string]string) string {
+	return data["oauth_token"]
+}
+
+func (p oauth1OAuthProvider) Validate(data types.JSONStringData) error {
+	if len(data["denied"]) > 0 {
+		return OAuthErrorAccessDenied()
+	}
+
+	return p.OAuthProviderBase.Validate(data)
+}
+
+func (p oauth1OAuthProvider) Signer() (OAuth1Signer, error) {
+	signer := OAuth1Signer{
+		ConsumerKey:    p.config.ClientID(),
+		ConsumerSecret: p.config.ClientSecret(),
+		Method:         OAuth1HMACSHA1,
+	}
+
+	if method, ok := configString(p.config, ConfigSignatureMethod); ok {
+		signer.Method = method
+	}
+
+	if signer.Method == OAuth1RSASHA1 {
+		key, err := oAuth1PrivateKey(p.config.GetExtra(ConfigPrivateKey))
+		if err != nil {
+			return signer, err
+		}
+		signer.PrivateKey = key
+	}
+
+	return signer, nil
+}
+
+func oAuth1PrivateKey(val any) (*rsa.PrivateKey, error) {
+	var raw []byte
+	switch key := val.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case string:
+		raw = []byte(key)
+	case []byte:
+		raw = key
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, OAuthErrorInvalidConfig(ConfigPrivateKey)
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if key, ok := parsed.(*rsa.PrivateKey); err == nil && ok {
+		return key, nil
+	}
+
+	return nil, OAuthErrorInvalidConfig(ConfigPrivateKey)
+}
+
+// signedCall sends a request signed with the given credentials and returns the raw response
+func (p oauth1OAuthProvider) signedCall(ctx context.Context, method string, endpoint string, token string, secret string, extra map[string]string, mode OAuthRetryMode) (*OAuthResponse, error) {
+	signer, err := p.Signer()
+	if err != nil {
+		return nil, err
+	}
+
+	uri, err := ParseURI(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	base := HTTPClientFromContext(p.withClient(ctx))
+	client := &http.Client{
+		Transport:     oAuth1TransportExtra(signer, token, secret, extra, base.Transport),
+		CheckRedirect: base.CheckRedirect,
+		Timeout:       base.Timeout,
+	}
+
+	policy := p.retry
+	if policy == nil {
+		policy = DefaultRetryPolicy()
+	}
+
+	return OAuthDoRequestContext(ctx, uri, RequestOptions(
+		WithReqOptMethod(method),
+		WithReqOptClient(client),
+		WithReqOptRetry(mode),
+		WithReqOptRetryPolicy(policy),
+	))
+}
+
+func (p oauth1OAuthProvider) credentials(ctx context.Context, endpoint string, token string, secret string, extra map[string]string) (url.Values, error) {
+	res, err := p.signedCall(ctx, http.MethodPost, endpoint, token, secret, extra, RetryConnectOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := url.ParseQuery(string(res.Body))
+	if err != nil || len(values.Get("oauth_token")) < 1 {
+		return nil, OAuthErrorToken()
+	}
+
+	return values, nil
+}
+
+func (p oauth1OAuthProvider) Initialize(opts *oAuthOptions, scopes ...string) (*OAuthRequestResult, error) {
+	return p.InitializeContext(context.Background(), opts, scopes...)
+}
+
+func (p oauth1OAuthProvider) InitializeContext(ctx context.Context, opts *oAuthOptions, scopes ...string) (*OAuthRequestResult, error) {
+	callback := "oob"
+	if len(opts.Redirect) > 0 {
+		callback = opts.Redirect
+	}
+
+	values, err := p.credentials(ctx, p.endpoints.RequestToken, "", "", map[string]string{
+		"oauth_callback": callback,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if values.Get("oauth_callback_confirmed") != "true" {
+		return nil, OAuthErrorToken()
+	}
+
+	// the service seals both into the state, keyed by the request token
+	opts.StateData["oauth_token"] = values.Get("oauth_token")
+	opts.StateData["oauth_token_secret"] = values.Get("oauth_token_secret")
+
+	uri, err := ParseURI(p.endpoints.Authorize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OAuthRequestResult{
+		Type: OAuthRequestRedirect,
+		Data: uri.Set("oauth_token", values.Get("oauth_token")).String(),
+	}, nil
+}
+
+func (p oauth1OAuthProvider) Callback(opts *oAuthOptions) (*OAuthToken, error) {
+	return p.CallbackContext(context.Background(), opts)
+}
+
+func (p oauth1OAuthProvider) CallbackContext(ctx context.Context, opts *oAuthOptions) (*OAuthToken, error) {
+	token, err := opts.GetConfigString("oauth_token")
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, err := opts.GetConfigString("oauth_verifier")
+	if err != nil {
+		return nil, err
+	}
+
+	// only a state resolved for this very request token carries its secret
+	secret := opts.StateData["oauth_token_secret"]
+	if opts.StateData["oauth_token"] != token || len(secret) < 1 {
+		return nil, OAuthErrorStateExpired()
+	}
+
+	values, err := p.credentials(ctx, p.endpoints.AccessToken, token, secret, map[string]string{
+		"oauth_verifier": verifier,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &OAuthToken{
+		AccessToken: values.Get("oauth_token"),
+		TokenSecret: values.Get("oauth_token_secret"),
+		TokenType:   "OAuth",
+		Raw:         make(map[string]any),
+	}
+
+	for key := range values {
+		if key != "oauth_token" && key != "oauth_token_secret" {
+			result.Raw[key] = values.Get(key)
+		}
+	}
+
+	return result, nil
+}
+
+func (p oauth1OAuthProvider) AuthorizeRequest(req *http.Request, token *OAuthToken) error {
+	signer, err := p.Signer()
+	if err != nil {
+		return err
+	}
+
+	return signer.Sign(req, token.AccessToken, token.TokenSecret, nil)
+}
+
+func (p oauth1OAuthProvider) TokenToUser(token *OAuthToken) (*OAuthUser, error) {
+	return p.TokenToUserContext(context.Background(), token)
+}
+
+func (p oauth1OAuthProvider) TokenToUserContext(ctx context.Context, token *OAuthToken) (*OAuthUser, error) {
+	profile := make(types.JSONDumpData)
+	for key, val := range token.Raw {
+		profile[key] = val
+	}
+
+	if len(p.endpoints.Profile) > 0 {
+		res, err := p.signedCall(ctx, http.MethodGet, p.endpoints.Profile, token.AccessToken, token.TokenSecret, nil, RetryAuto)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := DecodeResponse(res)
+		if err != nil {
+			return nil, OAuthErrorDecodeFailed()
+		}
+
+		for key, val := range data {
+			profile[key] = val
+		}
+	}
+
+	user := &OAuthUser{
+		IdentityType: "email",
+		AccessToken:  token.AccessToken,
+		Profile:      profile,
+	}
+
+	for _, key := range []string{"id_str", "user_id", "id"} {
+		switch val := profile[key].(type) {
+		case string:
+			user.UserID = val
+		case float64:
+			user.UserID = strconv.FormatFloat(val, 'f', -1, 64)
+		}
+
+		if len(user.UserID) > 0 {
+			break
+		}
+	}
+
+	if len(user.UserID) < 1 {
+		return nil, OAuthErrorDecodeFailed()
+	}
+
+	user.Identity, _ = profile["email"].(string)
+	if len(user.Identity) < 1 {
+		user.IdentityType = "username"
+		user.Identity, _ = profile["screen_name"].(string)
+	}
+
+	return user, nil
+}
+
+// OAuth1 fits an OAuth 1.0a provider behind OAuthServiceProvider. The config's client
+// id and secret are the consumer credentials, set the ConfigSignatureMethod extra to
+// RSA-SHA1 or PLAINTEXT to change from HMAC-SHA1, RSA-SHA1 also needs ConfigPrivateKey.
+// The request token secret is kept in the service's StateStore between Initialize and Callback.
+func OAuth1(name string, endpoints OAuth1Endpoints, config OAuthConfig, cbs ...OAuthProviderCallback) *oauth1OAuthProvider {
+	return &oauth1OAuthProvider{
+		name:      name,
+		endpoints: endpoints,
+		OAuthProviderBase: (&OAuthProviderBase{
+			config: config,
+		}).apply(cbs),
+	}
+}
+
+func TwitterOAuth1(config OAuthConfig, cbs ...OAuthProviderCallback) *oauth1OAuthProvider {
+	return OAuth1("twitter", OAuth1Endpoints{
+		RequestToken: "https://api.twitter.com/oauth/request_token",
+		Authorize:    "https://api.twitter.com/oauth/authenticate",
+		AccessToken:  "https://api.twitter.com/oauth/access_token",
+		Profile:      "https://api.twitter.com/1.1/account/verify_credentials.json?include_email=true&skip_status=true",
+	}, config, cbs...)
+}
@@ -0,0 +1,106 @@
+package oauth
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOAuth1BaseString(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		body     string
+		protocol map[string]string
+		expected string
+	}{
+		{
+			// RFC 5849 section 3.4.1.1
+			name:   "rfc5849",
+			method: http.MethodPost,
+			url:    "http://example.com/request?b5=%3D%253D&a3=a&c%40=&a2=r%20b",
+			body:   "c2&a3=2+q",
+			protocol: map[string]string{
+				"oauth_consumer_key":     "9djdj82h48djs9d2",
+				"oauth_token":            "kkk9d7dh3k39sjv7",
+				"oauth_signature_method": "HMAC-SHA1",
+				"oauth_timestamp":        "137131201",
+				"oauth_nonce":            "7d8f3e4a",
+			},
+			expected: "POST&http%3A%2F%2Fexample.com%2Frequest&a2%3Dr%2520b%26a3%3D2%2520q%26a3%3Da%26b5%3D%253D%25253D%26c%2540%3D%26c2%3D%26oauth_consumer_key%3D9djdj82h48djs9d2%26oauth_nonce%3D7d8f3e4a%26oauth_signature_method%3DHMAC-SHA1%26oauth_timestamp%3D137131201%26oauth_token%3Dkkk9d7dh3k39sjv7",
+		},
+		{
+			name:     "name sorts before value",
+			method:   http.MethodGet,
+			url:      "https://example.com:443/path?a1=x&a=z",
+			protocol: map[string]string{},
+			expected: "GET&https%3A%2F%2Fexample.com%2Fpath&a%3Dz%26a1%3Dx",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tt.body) > 0 {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+
+			base, err := oAuth1BaseString(req, tt.protocol)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if base != tt.expected {
+				t.Errorf("base string\n got: %s\nwant: %s", base, tt.expected)
+			}
+		})
+	}
+}
+
+func TestOAuth1Signature(t *testing.T) {
+	tests := []struct {
+		name        string
+		signer      OAuth1Signer
+		base        string
+		tokenSecret string
+		expected    string
+	}{
+		{
+			// Twitter's "Creating a signature" walkthrough
+			name: "hmac-sha1",
+			signer: OAuth1Signer{
+				ConsumerSecret: "kAcSOqF21Fu85e7zjz7ZN2U4ZRhfV3WpwPAoE3Z7kBw",
+				Method:         OAuth1HMACSHA1,
+			},
+			base:        "POST&https%3A%2F%2Fapi.twitter.com%2F1.1%2Fstatuses%2Fupdate.json&include_entities%3Dtrue%26oauth_consumer_key%3Dxvz1evFS4wEEPTGEFPHBog%26oauth_nonce%3DkYjzVBB8Y0ZFabxSWbWovY3uYSQ2pTgmZeNu2VS4cg%26oauth_signature_method%3DHMAC-SHA1%26oauth_timestamp%3D1318622958%26oauth_token%3D370773112-GmHxMAgYyLbNEtIKZeRNFsMKPR9EyMZeS9weJAEb%26oauth_version%3D1.0%26status%3DHello%2520Ladies%2520%252B%2520Gentlemen%252C%2520a%2520signed%2520OAuth%2520request%2521",
+			tokenSecret: "LswwdoUaIvS8ltyTt5jkRh4J50vUPVVHtR2YPi5kE",
+			expected:    "hCtSmYh+iHYCEqBWrE7C7hYmtUk=",
+		},
+		{
+			name: "plaintext",
+			signer: OAuth1Signer{
+				ConsumerSecret: "kd94hf93k423kf44",
+				Method:         OAuth1Plaintext,
+			},
+			tokenSecret: "pfkkdhi9sl3r4s00",
+			expected:    "kd94hf93k423kf44&pfkkdhi9sl3r4s00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig, err := tt.signer.signature(tt.base, tt.tokenSecret)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if sig != tt.expected {
+				t.Errorf("signature = %s, want %s", sig, tt.expected)
+			}
+		})
+	}
+}
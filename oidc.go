@@ -0,0 +1,420 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DecxBase/core/types"
+	"github.com/DecxBase/core/utils"
+)
+
+const DefaultDiscoveryMaxAge = time.Hour
+
+type OIDCDiscovery struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+func CacheMaxAge(header http.Header, def time.Duration) time.Duration {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+
+		if directive == "no-store" || directive == "no-cache" {
+			return 0
+		}
+
+		if strings.HasPrefix(directive, "max-age=") {
+			seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+			if err == nil && seconds >= 0 {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+
+	return def
+}
+
+type oidcOAuthProvider struct {
+	*OAuthProviderBase
+	issuer string
+
+	mu        sync.Mutex
+	discovery *OIDCDiscovery
+	expiresAt time.Time
+}
+
+func (p *oidcOAuthProvider) Name() string {
+	if name, ok := p.config.GetExtra("name").(string); ok && len(name) > 0 {
+		return name
+	}
+
+	return "oidc"
+}
+
+func (p *oidcOAuthProvider) fetchDiscovery(ctx context.Context) (*OIDCDiscovery, time.Duration, error) {
+	uri, err := ParseURI(strings.TrimSuffix(p.issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	res, err := OAuthDoRequestContext(p.withClient(ctx), uri, RequestOptions(
+		WithReqHeader("Accept", "application/json"),
+		WithReqOptRetryPolicy(DefaultRetryPolicy()),
+	))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var discovery OIDCDiscovery
+	if err := json.Unmarshal(res.Body, &discovery); err != nil {
+		return nil, 0, OAuthErrorDecodeFailed()
+	}
+
+	if discovery.Issuer != p.issuer {
+		return nil, 0, OAuthError{
+			Reason:  "discovery",
+			Message: fmt.Sprintf("Discovery issuer [%s] doesn't match [%s]", discovery.Issuer, p.issuer),
+		}
+	}
+
+	for _, endpoint := range []string{
+		discovery.AuthorizationEndpoint,
+		discovery.TokenEndpoint,
+		discovery.JWKSURI,
+	} {
+		if _, err := ParseURI(endpoint); err != nil || len(endpoint) < 1 {
+			return nil, 0, OAuthError{
+				Reason:  "discovery",
+				Message: "Discovery document is missing required endpoints",
+			}
+		}
+	}
+
+	return &discovery, CacheMaxAge(res.Header, DefaultDiscoveryMaxAge), nil
+}
+
+func (p *oidcOAuthProvider) Discovery() (*OIDCDiscovery, error) {
+	return p.DiscoveryContext(context.Background())
+}
+
+func (p *oidcOAuthProvider) DiscoveryContext(ctx context.Context) (*OIDCDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil && time.Now().Before(p.expiresAt) {
+		return p.discovery, nil
+	}
+
+	discovery, maxAge, err := p.fetchDiscovery(ctx)
+	if err != nil {
+		// a stale document beats failing logins while the issuer is unreachable
+		if p.discovery != nil {
+			return p.discovery, nil
+		}
+
+		return nil, err
+	}
+
+	p.discovery = discovery
+	p.expiresAt = time.Now().Add(maxAge)
+	p.keys.SetURI(discovery.JWKSURI)
+
+	return discovery, nil
+}
+
+// PKCEMethod only looks at an already cached discovery document, since it can't
+// carry a context. S256 is assumed until the issuer says otherwise.
+func (p *oidcOAuthProvider) PKCEMethod() string {
+	p.mu.Lock()
+	discovery := p.discovery
+	p.mu.Unlock()
+
+	return pkceMethodFor(discovery)
+}
+
+// PKCEMethodContext fetches discovery first, so the very first login already uses
+// the method the issuer supports
+func (p *oidcOAuthProvider) PKCEMethodContext(ctx context.Context) (string, error) {
+	discovery, err := p.DiscoveryContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return pkceMethodFor(discovery), nil
+}
+
+func pkceMethodFor(discovery *OIDCDiscovery) string {
+	if discovery == nil || len(discovery.CodeChallengeMethodsSupported) < 1 {
+		return PKCEMethodS256
+	}
+
+	if utils.CheckContains(discovery.CodeChallengeMethodsSupported, PKCEMethodS256) {
+		return PKCEMethodS256
+	}
+
+	if utils.CheckContains(discovery.CodeChallengeMethodsSupported, PKCEMethodPlain) {
+		return PKCEMethodPlain
+	}
+
+	return ""
+}
+
+func (p *oidcOAuthProvider) tokenRequestOptions(discovery *OIDCDiscovery) *oAuthRequestOptions {
+	opts := RequestOptions(
+		WithReqOptMethod(http.MethodPost),
+		WithReqOptForm(),
+		WithReqHeader("Accept", "application/json"),
+	)
+
+	// client_secret_basic is the default when the issuer doesn't advertise anything
+	methods := discovery.TokenEndpointAuthMethodsSupported
+	if len(methods) < 1 || utils.CheckContains(methods, "client_secret_basic") {
+		WithReqBasicAuth(p.config.ClientID(), p.config.ClientSecret())(opts)
+	}
+
+	return opts
+}
+
+func (p *oidcOAuthProvider) tokenBody(discovery *OIDCDiscovery, uri *oAuthURI, opts *oAuthRequestOptions) *oAuthURI {
+	uri.SetURL(discovery.TokenEndpoint).
+		SetBody("client_id", p.config.ClientID())
+
+	if len(opts.Headers["Authorization"]) < 1 {
+		uri.SetBody("client_secret", p.config.ClientSecret())
+	}
+
+	return uri
+}
+
+func (p *oidcOAuthProvider) Initialize(opts *oAuthOptions, scopes ...string) (*OAuthRequestResult, error) {
+	return p.InitializeContext(context.Background(), opts, scopes...)
+}
+
+func (p *oidcOAuthProvider) InitializeContext(ctx context.Context, opts *oAuthOptions, scopes ...string) (*OAuthRequestResult, error) {
+	discovery, err := p.DiscoveryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rScopes := ResolveScopes(
+		[]string{
+			"openid",
+		},
+		scopes,
+		"profile",
+		"email",
+	)
+
+	uri := p.client.Clone().SetURL(discovery.AuthorizationEndpoint).
+		Set("client_id", p.config.ClientID()).
+		Set("response_type", "code").
+		Set("scope", strings.Join(rScopes, " ")).
+		Set("redirect_uri", opts.Redirect).
+		SetIf(len(opts.State) > 0, "state", opts.State).
+		SetIf(len(opts.Nonce) > 0, "nonce", opts.Nonce).
+		SetIf(len(opts.Prompt) > 0, "prompt", opts.Prompt).
+		SetIf(opts.MaxAge > 0, "max_age", opts.maxAgeSeconds()).
+		SetIf(len(opts.CodeChallenge) > 0, "code_challenge", opts.CodeChallenge).
+		SetIf(len(opts.CodeChallenge) > 0, "code_challenge_method", opts.CodeChallengeMethod)
+
+	return &OAuthRequestResult{
+		Type: OAuthRequestRedirect,
+		Data: uri.String(),
+	}, nil
+}
+
+func (p *oidcOAuthProvider) Callback(opts *oAuthOptions) (*OAuthToken, error) {
+	return p.CallbackContext(context.Background(), opts)
+}
+
+func (p *oidcOAuthProvider) CallbackContext(ctx context.Context, opts *oAuthOptions) (*OAuthToken, error) {
+	discovery, err := p.DiscoveryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reqOpts := p.tokenRequestOptions(discovery)
+	WithReqOptRetry(RetryConnectOnly)(reqOpts)
+
+	code, err := opts.GetConfigString("code")
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := p.RunTokenRequestContext(ctx, func(uri *oAuthURI) *oAuthURI {
+		return p.tokenBody(discovery, uri, reqOpts).
+			SetBody("grant_type", "authorization_code").
+			SetBody("code", code).
+			SetBody("redirect_uri", opts.Redirect).
+			SetBodyIf(len(opts.CodeVerifier) > 0, "code_verifier", opts.CodeVerifier)
+	}, reqOpts, "access_token")
+
+	if err != nil {
+		return nil, err
+	}
+	return p.MakeTokenResult(data)
+}
+
+func (p *oidcOAuthProvider) RefreshToken(token string) (*OAuthToken, error) {
+	return p.RefreshTokenContext(context.Background(), token)
+}
+
+func (p *oidcOAuthProvider) RefreshTokenContext(ctx context.Context, token string) (*OAuthToken, error) {
+	discovery, err := p.DiscoveryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reqOpts := p.tokenRequestOptions(discovery)
+	data, err := p.RunTokenRequestContext(ctx, func(uri *oAuthURI) *oAuthURI {
+		return p.tokenBody(discovery, uri, reqOpts).
+			SetBody("grant_type", "refresh_token").
+			SetBody("refresh_token", token)
+	}, reqOpts, "access_token")
+
+	if err != nil {
+		return nil, err
+	}
+	return p.MakeTokenResult(data)
+}
+
+func (p *oidcOAuthProvider) VerifyIDToken(token string, nonce string) (types.JSONDumpData, error) {
+	return p.VerifyIDTokenContext(context.Background(), token, nonce)
+}
+
+func (p *oidcOAuthProvider) VerifyIDTokenContext(ctx context.Context, token string, nonce string) (types.JSONDumpData, error) {
+	if _, err := p.DiscoveryContext(ctx); err != nil {
+		return nil, err
+	}
+
+	return p.OAuthProviderBase.VerifyIDTokenContext(ctx, token, nonce)
+}
+
+func (p *oidcOAuthProvider) IDTokenClaims(ctx context.Context, token string) (types.JSONDumpData, error) {
+	if _, err := p.DiscoveryContext(ctx); err != nil {
+		return nil, err
+	}
+
+	return p.OAuthProviderBase.IDTokenClaims(ctx, token)
+}
+
+func (p *oidcOAuthProvider) TokenToUser(token *OAuthToken) (*OAuthUser, error) {
+	return p.TokenToUserContext(context.Background(), token)
+}
+
+func (p *oidcOAuthProvider) TokenToUserContext(ctx context.Context, token *OAuthToken) (*OAuthUser, error) {
+	var payload types.JSONDumpData
+	var err error
+
+	if len(token.IDToken) > 0 {
+		payload, err = p.IDTokenClaims(ctx, token.IDToken)
+	} else {
+		payload, err = p.GetContext(ctx, token.AccessToken, []string{"sub", "email", "email_verified"}, Options())
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	sub, _ := payload["sub"].(string)
+	if len(sub) < 1 {
+		return nil, OAuthErrorToken()
+	}
+
+	user := &OAuthUser{
+		UserID:       sub,
+		IdentityType: "sub",
+		Identity:     sub,
+		AccessToken:  token.AccessToken,
+		ExpiresIn:    token.ExpiresIn,
+	}
+
+	// unverified addresses can be claimed by anyone, so they never become the identity
+	if email, ok := payload["email"].(string); ok && len(email) > 0 && payload["email_verified"] == true {
+		user.IdentityType = "email"
+		user.Identity = email
+	}
+
+	return user, nil
+}
+
+func (p *oidcOAuthProvider) Get(token string, fields []string, opts *oAuthOptions) (types.JSONDumpData, error) {
+	return p.GetContext(context.Background(), token, fields, opts)
+}
+
+func (p *oidcOAuthProvider) GetContext(ctx context.Context, token string, fields []string, opts *oAuthOptions) (types.JSONDumpData, error) {
+	discovery, err := p.DiscoveryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(discovery.UserinfoEndpoint) < 1 {
+		return nil, OAuthErrorUnimplemented(p.Name(), "Get")
+	}
+
+	endpoint := discovery.UserinfoEndpoint
+	if len(opts.RequestPath) > 0 {
+		endpoint = opts.RequestPath
+	}
+
+	data, err := p.CallContext(ctx, func(uri *oAuthURI) *oAuthURI {
+		return uri.SetURL(endpoint)
+	}, RequestOptions(
+		WithReqHeader("Authorization", fmt.Sprintf("Bearer %s", token)),
+		WithReqHeader("Accept", "application/json"),
+	))
+
+	if err != nil {
+		return nil, err
+	}
+	return utils.PluckFields(data, fields), nil
+}
+
+func (p *oidcOAuthProvider) RevokeToken(token string) error {
+	return p.RevokeTokenContext(context.Background(), token)
+}
+
+func (p *oidcOAuthProvider) RevokeTokenContext(ctx context.Context, token string) error {
+	discovery, err := p.DiscoveryContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(discovery.RevocationEndpoint) < 1 {
+		return OAuthErrorUnimplemented(p.Name(), "RevokeToken")
+	}
+
+	reqOpts := p.tokenRequestOptions(discovery)
+	_, err = p.CallContext(ctx, func(uri *oAuthURI) *oAuthURI {
+		return p.tokenBody(discovery, uri, reqOpts).
+			SetURL(discovery.RevocationEndpoint).
+			SetBody("token", token)
+	}, reqOpts)
+
+	return err
+}
+
+func OIDCProvider(issuer string, config OAuthConfig, cbs ...OAuthProviderCallback) *oidcOAuthProvider {
+	return &oidcOAuthProvider{
+		issuer: issuer,
+		OAuthProviderBase: (&OAuthProviderBase{
+			config:  config,
+			client:  URIHost(""),
+			keys:    JWKS(""),
+			issuers: []string{issuer},
+		}).apply(cbs),
+	}
+}
@@ -0,0 +1,93 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DecxBase/core/types"
+)
+
+func TestOIDCTokenToUserEmail(t *testing.T) {
+	key, jwk := testJWK(t, "k1")
+
+	var userinfo types.JSONDumpData
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                 srv.URL,
+			"authorization_endpoint": srv.URL + "/authorize",
+			"token_endpoint":         srv.URL + "/token",
+			"jwks_uri":               srv.URL + "/jwks",
+			"userinfo_endpoint":      srv.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []OAuthJWK{jwk}})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(userinfo)
+	})
+
+	tests := []struct {
+		name     string
+		claims   types.JSONDumpData
+		kind     string
+		identity string
+	}{
+		{"verified", types.JSONDumpData{"email": "a@example.com", "email_verified": true}, "email", "a@example.com"},
+		{"unverified", types.JSONDumpData{"email": "a@example.com", "email_verified": false}, "sub", "123"},
+		{"verified as a string", types.JSONDumpData{"email": "a@example.com", "email_verified": "true"}, "sub", "123"},
+		{"without email_verified", types.JSONDumpData{"email": "a@example.com"}, "sub", "123"},
+		{"without email", types.JSONDumpData{}, "sub", "123"},
+	}
+
+	for _, tt := range tests {
+		claims := types.JSONDumpData{"sub": "123"}
+		for key, val := range tt.claims {
+			claims[key] = val
+		}
+
+		t.Run(tt.name+" id token", func(t *testing.T) {
+			now := time.Now()
+			idClaims := types.JSONDumpData{
+				"iss": srv.URL,
+				"aud": "client",
+				"exp": now.Add(time.Hour).Unix(),
+				"iat": now.Unix(),
+			}
+			for key, val := range claims {
+				idClaims[key] = val
+			}
+
+			provider := OIDCProvider(srv.URL, Config("client", "secret"))
+			user, err := provider.TokenToUser(&OAuthToken{AccessToken: "access", IDToken: signTestIDToken(t, key, idClaims)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if user.IdentityType != tt.kind || user.Identity != tt.identity || user.UserID != "123" {
+				t.Errorf("user = %+v, want %s %s", user, tt.kind, tt.identity)
+			}
+		})
+
+		t.Run(tt.name+" userinfo", func(t *testing.T) {
+			userinfo = claims
+
+			provider := OIDCProvider(srv.URL, Config("client", "secret"))
+			user, err := provider.TokenToUser(&OAuthToken{AccessToken: "access"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if user.IdentityType != tt.kind || user.Identity != tt.identity || user.UserID != "123" {
+				t.Errorf("user = %+v, want %s %s", user, tt.kind, tt.identity)
+			}
+		})
+	}
+}
@@ -1,21 +1,50 @@
 package oauth
 
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
 type oAuthOptions struct {
-	Redirect    string
-	AuthType    string
-	RequestPath string
-	Config      map[string]any
+	Redirect            string
+	RedirectAllowlist   *oAuthRedirectAllowlist
+	ReturnTo            string
+	AuthType            string
+	RequestPath         string
+	CodeVerifier        string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string
+	Prompt              string
+	MaxAge              time.Duration
+	State               string
+	StateData           map[string]string
+	StateStore          StateStore
+	TokenStore          TokenStore
+	HTTPClient          *http.Client
+	Config              map[string]any
 }
 
 func (o oAuthOptions) GetConfig(key string) any {
 	return o.Config[key]
 }
 
+func (o oAuthOptions) GetConfigString(key string) (string, error) {
+	val, _ := o.Config[key].(string)
+	if len(val) < 1 {
+		return "", OAuthErrorMissingParam(key)
+	}
+
+	return val, nil
+}
+
 type OAuthOptionCallback = func(*oAuthOptions)
 
 func Options(cbs ...OAuthOptionCallback) *oAuthOptions {
 	opts := &oAuthOptions{
-		Config: make(map[string]any),
+		StateData: make(map[string]string),
+		Config:    make(map[string]any),
 	}
 
 	for _, cb := range cbs {
@@ -28,9 +57,28 @@ func Options(cbs ...OAuthOptionCallback) *oAuthOptions {
 func WithOptions(opts *oAuthOptions) OAuthOptionCallback {
 	return func(o *oAuthOptions) {
 		o.Redirect = opts.Redirect
+		o.RedirectAllowlist = opts.RedirectAllowlist
+		o.ReturnTo = opts.ReturnTo
 		o.AuthType = opts.AuthType
 		o.RequestPath = opts.RequestPath
-		o.Config = opts.Config
+		o.CodeVerifier = opts.CodeVerifier
+		o.CodeChallenge = opts.CodeChallenge
+		o.CodeChallengeMethod = opts.CodeChallengeMethod
+		o.Nonce = opts.Nonce
+		o.Prompt = opts.Prompt
+		o.MaxAge = opts.MaxAge
+		o.State = opts.State
+		o.StateStore = opts.StateStore
+		o.TokenStore = opts.TokenStore
+		o.HTTPClient = opts.HTTPClient
+
+		for key, val := range opts.StateData {
+			o.StateData[key] = val
+		}
+
+		for key, val := range opts.Config {
+			o.Config[key] = val
+		}
 	}
 }
 
@@ -40,6 +88,21 @@ func WithOptRedirect(redirect string) OAuthOptionCallback {
 	}
 }
 
+// WithOptRedirectAllowlist validates redirect_uri and return_to before they are used
+func WithOptRedirectAllowlist(list *oAuthRedirectAllowlist) OAuthOptionCallback {
+	return func(o *oAuthOptions) {
+		o.RedirectAllowlist = list
+	}
+}
+
+// WithOptReturnTo sets where the app sends the user after login, it's bound to the state
+// and requires a StateStore
+func WithOptReturnTo(target string) OAuthOptionCallback {
+	return func(o *oAuthOptions) {
+		o.ReturnTo = target
+	}
+}
+
 func WithOptAuthType(auth_type string) OAuthOptionCallback {
 	return func(o *oAuthOptions) {
 		o.AuthType = auth_type
@@ -52,6 +115,80 @@ func WithOptRequestPath(req_path string) OAuthOptionCallback {
 	}
 }
 
+func WithOptPKCE(pkce *OAuthPKCE) OAuthOptionCallback {
+	return func(o *oAuthOptions) {
+		o.CodeVerifier = pkce.Verifier
+		o.CodeChallenge = pkce.Challenge
+		o.CodeChallengeMethod = pkce.Method
+	}
+}
+
+func WithOptCodeVerifier(verifier string) OAuthOptionCallback {
+	return func(o *oAuthOptions) {
+		o.CodeVerifier = verifier
+	}
+}
+
+func WithOptNonce(nonce string) OAuthOptionCallback {
+	return func(o *oAuthOptions) {
+		o.Nonce = nonce
+	}
+}
+
+func WithOptPrompt(prompt string) OAuthOptionCallback {
+	return func(o *oAuthOptions) {
+		o.Prompt = prompt
+	}
+}
+
+// WithOptMaxAge asks the provider to re-authenticate users who signed in longer ago than `age`
+func WithOptMaxAge(age time.Duration) OAuthOptionCallback {
+	return func(o *oAuthOptions) {
+		o.MaxAge = age
+	}
+}
+
+func (o oAuthOptions) maxAgeSeconds() string {
+	return strconv.FormatInt(int64(o.MaxAge/time.Second), 10)
+}
+
+func WithOptState(state string) OAuthOptionCallback {
+	return func(o *oAuthOptions) {
+		o.State = state
+	}
+}
+
+func WithOptStateData(key string, val string) OAuthOptionCallback {
+	return func(o *oAuthOptions) {
+		o.StateData[key] = val
+	}
+}
+
+// WithOptStateStore replaces the service's StateStore, a nil store makes Initialize and
+// Callback fail instead of skipping the state check
+func WithOptStateStore(store StateStore) OAuthOptionCallback {
+	return func(o *oAuthOptions) {
+		o.StateStore = store
+	}
+}
+
+// WithOptTokenStore saves tokens obtained through Callback and RefreshToken
+func WithOptTokenStore(store TokenStore) OAuthOptionCallback {
+	return func(o *oAuthOptions) {
+		o.TokenStore = store
+	}
+}
+
+func WithOptHTTPClient(client *http.Client) OAuthOptionCallback {
+	return func(o *oAuthOptions) {
+		o.HTTPClient = client
+	}
+}
+
+func WithOptTransport(transport http.RoundTripper) OAuthOptionCallback {
+	return WithOptHTTPClient(&http.Client{Transport: transport})
+}
+
 func WithOptConfig(key string, val any) OAuthOptionCallback {
 	return func(o *oAuthOptions) {
 		o.Config[key] = val
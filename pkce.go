@@ -0,0 +1,54 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+const (
+	PKCEMethodS256  = "S256"
+	PKCEMethodPlain = "plain"
+)
+
+type OAuthPKCE struct {
+	Verifier  string
+	Challenge string
+	Method    string
+}
+
+func RandomString(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func PKCEChallenge(verifier string, method string) string {
+	if method == PKCEMethodPlain {
+		return verifier
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func GeneratePKCE(method string) (*OAuthPKCE, error) {
+	if method != PKCEMethodPlain {
+		method = PKCEMethodS256
+	}
+
+	// 32 random bytes encode to a 43 character verifier, the minimum allowed by RFC 7636
+	verifier, err := RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OAuthPKCE{
+		Verifier:  verifier,
+		Challenge: PKCEChallenge(verifier, method),
+		Method:    method,
+	}, nil
+}
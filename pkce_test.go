@@ -0,0 +1,34 @@
+package oauth
+
+import "testing"
+
+func TestPKCEChallenge(t *testing.T) {
+	tests := []struct {
+		name     string
+		verifier string
+		method   string
+		expected string
+	}{
+		{
+			// RFC 7636 appendix B
+			name:     "s256",
+			verifier: "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk",
+			method:   PKCEMethodS256,
+			expected: "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+		},
+		{
+			name:     "plain",
+			verifier: "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk",
+			method:   PKCEMethodPlain,
+			expected: "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if challenge := PKCEChallenge(tt.verifier, tt.method); challenge != tt.expected {
+				t.Errorf("challenge = %s, want %s", challenge, tt.expected)
+			}
+		})
+	}
+}
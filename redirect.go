@@ -0,0 +1,134 @@
+package oauth
+
+import (
+	"net/url"
+	"strings"
+)
+
+type oAuthRedirectRule struct {
+	scheme string
+	host   string
+	suffix bool
+	target string
+}
+
+type oAuthRedirectAllowlist struct {
+	relative bool
+	rules    []oAuthRedirectRule
+}
+
+// RedirectAllowlist accepts three kinds of patterns: "/" allows relative paths on the
+// same origin, "*.example.com" allows https on any subdomain (prefix "http://" to allow
+// plain http) and anything else has to match a target exactly, https unless it says otherwise
+func RedirectAllowlist(patterns ...string) *oAuthRedirectAllowlist {
+	list := &oAuthRedirectAllowlist{
+		rules: make([]oAuthRedirectRule, 0, len(patterns)),
+	}
+
+	for _, pattern := range patterns {
+		if pattern == "/" {
+			list.relative = true
+			continue
+		}
+
+		scheme, host := "https", pattern
+		if before, after, ok := strings.Cut(pattern, "://"); ok {
+			scheme, host = strings.ToLower(before), after
+		}
+
+		if strings.HasPrefix(host, "*.") {
+			list.rules = append(list.rules, oAuthRedirectRule{
+				scheme: scheme,
+				host:   strings.ToLower(strings.TrimPrefix(host, "*")),
+				suffix: true,
+			})
+			continue
+		}
+
+		// scheme-less patterns default to https, same as the wildcard ones
+		if normalized, ok := normalizeRedirect(scheme + "://" + host); ok {
+			list.rules = append(list.rules, oAuthRedirectRule{target: normalized})
+		}
+	}
+
+	return list
+}
+
+func isLocalPath(target string) bool {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.ContainsAny(target, "\\\r\n\t") {
+		return false
+	}
+
+	parsed, err := url.Parse(target)
+	return err == nil && len(parsed.Scheme) < 1 && len(parsed.Host) < 1
+}
+
+func normalizeRedirect(target string) (string, bool) {
+	parsed, err := url.Parse(target)
+	if err != nil || parsed.User != nil || len(parsed.Fragment) > 0 || strings.ContainsAny(target, "\\\r\n\t") {
+		return "", false
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	if (parsed.Scheme != "https" && parsed.Scheme != "http") || len(parsed.Host) < 1 {
+		return "", false
+	}
+
+	return parsed.String(), true
+}
+
+func (l oAuthRedirectAllowlist) allowedAbsolute(target string) bool {
+	normalized, ok := normalizeRedirect(target)
+	if !ok {
+		return false
+	}
+
+	parsed, _ := url.Parse(normalized)
+	for _, rule := range l.rules {
+		if rule.suffix {
+			if parsed.Scheme == rule.scheme && strings.HasSuffix(parsed.Hostname(), rule.host) {
+				return true
+			}
+		} else if normalized == rule.target {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Allowed validates an app-level destination such as return_to
+func (l oAuthRedirectAllowlist) Allowed(target string) bool {
+	if isLocalPath(target) {
+		return l.relative
+	}
+
+	return l.allowedAbsolute(target)
+}
+
+// AllowedRedirectURI validates a provider redirect_uri, which always has to be absolute
+func (l oAuthRedirectAllowlist) AllowedRedirectURI(target string) bool {
+	return l.allowedAbsolute(target)
+}
+
+// checkReturnTo falls back to same-origin paths only when no allowlist is configured
+func checkReturnTo(list *oAuthRedirectAllowlist, target string) error {
+	if len(target) < 1 {
+		return nil
+	}
+
+	if (list == nil && isLocalPath(target)) || (list != nil && list.Allowed(target)) {
+		return nil
+	}
+
+	return OAuthErrorRedirectNotAllowed(target)
+}
+
+func checkRedirectURI(list *oAuthRedirectAllowlist, target string) error {
+	if list == nil || list.AllowedRedirectURI(target) {
+		return nil
+	}
+
+	return OAuthErrorRedirectNotAllowed(target)
+}
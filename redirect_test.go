@@ -0,0 +1,70 @@
+package oauth
+
+import "testing"
+
+func TestRedirectAllowlist(t *testing.T) {
+	list := RedirectAllowlist(
+		"/",
+		"*.example.com",
+		"app.example.org/cb",
+		"http://localhost:8080/cb",
+	)
+
+	tests := []struct {
+		target  string
+		allowed bool
+	}{
+		{"/dashboard?tab=1", true},
+		{"//evil.test/x", false},
+		{"/\\evil.test", false},
+		{"/\t/evil.test", false},
+		{"https://a.example.com/x", true},
+		{"https://a.b.example.com/x", true},
+		{"https://A.EXAMPLE.COM/x", true},
+		{"http://a.example.com/x", false},
+		{"https://example.com/x", false},
+		{"https://evilexample.com/x", false},
+		{"https://a.example.com.evil.test/x", false},
+		{"https://a.example.com@evil.test/x", false},
+		{"https://user@a.example.com/x", false},
+		{"https://a.example.com\\@evil.test", false},
+		{"https://a.example.com/x#frag", false},
+		{"javascript://a.example.com/%0aalert(1)", false},
+		{"https://app.example.org/cb", true},
+		{"HTTPS://APP.EXAMPLE.ORG/cb", true},
+		{"https://app.example.org/CB", false},
+		{"http://app.example.org/cb", false},
+		{"https://app.example.org/cb/extra", false},
+		{"http://localhost:8080/cb", true},
+		{"http://localhost:8081/cb", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			if allowed := list.Allowed(tt.target); allowed != tt.allowed {
+				t.Errorf("Allowed(%q) = %v, want %v", tt.target, allowed, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestRedirectURIAllowlist(t *testing.T) {
+	list := RedirectAllowlist("/", "app.example.org/cb")
+
+	tests := []struct {
+		target  string
+		allowed bool
+	}{
+		{"https://app.example.org/cb", true},
+		{"/cb", false},
+		{"https://app.example.org/cb?x=1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			if allowed := list.AllowedRedirectURI(tt.target); allowed != tt.allowed {
+				t.Errorf("AllowedRedirectURI(%q) = %v, want %v", tt.target, allowed, tt.allowed)
+			}
+		})
+	}
+}
@@ -1,18 +1,39 @@
 package oauth
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 
 	"github.com/DecxBase/core/types"
 )
 
 type oAuthRequestOptions struct {
-	Method  string
-	Body    io.Reader
-	Headers types.JSONStringData
+	Method      string
+	Body        io.Reader
+	Headers     types.JSONStringData
+	Client      *http.Client
+	Retry       OAuthRetryMode
+	RetryPolicy *OAuthRetryPolicy
+}
+
+type oAuthClientKey struct{}
+
+func WithHTTPClient(ctx context.Context, client *http.Client) context.Context {
+	return context.WithValue(ctx, oAuthClientKey{}, client)
+}
+
+func HTTPClientFromContext(ctx context.Context) *http.Client {
+	if client, ok := ctx.Value(oAuthClientKey{}).(*http.Client); ok && client != nil {
+		return client
+	}
+
+	return http.DefaultClient
 }
 
 type OAuthRequestOptionCallback = func(*oAuthRequestOptions)
@@ -48,39 +69,155 @@ func WithReqHeader(key string, value string) OAuthRequestOptionCallback {
 	}
 }
 
+func WithReqOptClient(client *http.Client) OAuthRequestOptionCallback {
+	return func(o *oAuthRequestOptions) {
+		o.Client = client
+	}
+}
+
+func WithReqOptRetry(mode OAuthRetryMode) OAuthRequestOptionCallback {
+	return func(o *oAuthRequestOptions) {
+		o.Retry = mode
+	}
+}
+
+func WithReqOptRetryPolicy(policy *OAuthRetryPolicy) OAuthRequestOptionCallback {
+	return func(o *oAuthRequestOptions) {
+		o.RetryPolicy = policy
+	}
+}
+
+func WithReqOptForm() OAuthRequestOptionCallback {
+	return WithReqHeader("Content-Type", "application/x-www-form-urlencoded")
+}
+
+func WithReqBasicAuth(username string, password string) OAuthRequestOptionCallback {
+	credentials := url.QueryEscape(username) + ":" + url.QueryEscape(password)
+	return WithReqHeader("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+}
+
 func OAuthRequestURI(uri *oAuthURI) ([]byte, error) {
 	return OAuthRequest(uri, RequestOptions())
 }
 
+func OAuthRequestURIContext(ctx context.Context, uri *oAuthURI) ([]byte, error) {
+	return OAuthRequestContext(ctx, uri, RequestOptions())
+}
+
+const MaxResponseSize = 1 << 20
+
+var errResponseTooLarge = fmt.Errorf("client: response body exceeds %d bytes", MaxResponseSize)
+
+type OAuthResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
 func OAuthRequest(uri *oAuthURI, opts *oAuthRequestOptions) ([]byte, error) {
-	req, err := http.NewRequest(opts.Method, uri.String(), opts.Body)
+	return OAuthRequestContext(context.Background(), uri, opts)
+}
+
+func OAuthRequestContext(ctx context.Context, uri *oAuthURI, opts *oAuthRequestOptions) ([]byte, error) {
+	res, err := OAuthDoRequestContext(ctx, uri, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Body, nil
+}
+
+func OAuthDoRequest(uri *oAuthURI, opts *oAuthRequestOptions) (*OAuthResponse, error) {
+	return OAuthDoRequestContext(context.Background(), uri, opts)
+}
+
+func OAuthDoRequestContext(ctx context.Context, uri *oAuthURI, opts *oAuthRequestOptions) (*OAuthResponse, error) {
+	var payload []byte
+	if opts.Body != nil {
+		body, err := io.ReadAll(opts.Body)
+		if err != nil {
+			return nil, fmt.Errorf("client: could not read request body: %w", err)
+		}
+		payload = body
+	}
+
+	policy := opts.RetryPolicy
+	if policy == nil {
+		policy = NoRetryPolicy()
+	}
+
+	for attempt := 0; ; attempt++ {
+		response, err := oAuthSend(ctx, uri, opts, payload)
+
+		retry := attempt+1 < policy.MaxAttempts && policy.shouldRetry(opts, response, err)
+		if retry && policy.wait(ctx, attempt, response) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if response.StatusCode < 200 || response.StatusCode > 299 {
+			return response, OAuthErrorResponse(response)
+		}
+
+		return response, nil
+	}
+}
+
+func oAuthSend(ctx context.Context, uri *oAuthURI, opts *oAuthRequestOptions, payload []byte) (*OAuthResponse, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, opts.Method, uri.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("client: could not create request: %w", err)
+	}
+
 	if len(opts.Headers) > 0 {
 		for hkey, hval := range opts.Headers {
 			req.Header.Set(hkey, hval)
 		}
 	}
 
-	if err != nil {
-		return nil, fmt.Errorf("client: could not create request: %s", err)
+	client := opts.Client
+	if client == nil {
+		client = HTTPClientFromContext(ctx)
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := client.Do(req)
 
 	if err != nil {
-		return nil, fmt.Errorf("client: error making http request: %s", err)
+		return nil, fmt.Errorf("client: error making http request: %w", err)
 	}
+	defer res.Body.Close()
 
-	resBody, err := io.ReadAll(res.Body)
+	resBody, err := io.ReadAll(io.LimitReader(res.Body, MaxResponseSize+1))
 
 	if err != nil {
-		return nil, fmt.Errorf("client: could not read response body: %s", err)
+		return nil, fmt.Errorf("client: could not read response body: %w", err)
 	}
 
-	return resBody, nil
+	if len(resBody) > MaxResponseSize {
+		return nil, errResponseTooLarge
+	}
+
+	return &OAuthResponse{
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+		Body:       resBody,
+	}, nil
 }
 
 func OAuthRequestJSON(uri *oAuthURI, opts *oAuthRequestOptions) (map[string]any, error) {
-	res, err := OAuthRequest(uri, opts)
+	return OAuthRequestJSONContext(context.Background(), uri, opts)
+}
+
+func OAuthRequestJSONContext(ctx context.Context, uri *oAuthURI, opts *oAuthRequestOptions) (map[string]any, error) {
+	res, err := OAuthRequestContext(ctx, uri, opts)
 
 	if err != nil {
 		return nil, err
@@ -90,7 +227,7 @@ func OAuthRequestJSON(uri *oAuthURI, opts *oAuthRequestOptions) (map[string]any,
 	err = json.Unmarshal(res, &jsonData)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode json response: %s", err)
+		return nil, fmt.Errorf("failed to decode json response: %w", err)
 	}
 
 	return jsonData, nil
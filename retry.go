@@ -0,0 +1,145 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type OAuthRetryMode int
+
+const (
+	// RetryAuto retries idempotent methods, anything else only when it never reached the provider
+	RetryAuto OAuthRetryMode = iota
+	// RetrySafe marks a non-idempotent request as safe to send twice
+	RetrySafe
+	// RetryConnectOnly never resends a request the provider may have processed
+	RetryConnectOnly
+)
+
+type OAuthRetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+func DefaultRetryPolicy() *OAuthRetryPolicy {
+	return &OAuthRetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   200 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+	}
+}
+
+func NoRetryPolicy() *OAuthRetryPolicy {
+	return &OAuthRetryPolicy{
+		MaxAttempts: 1,
+	}
+}
+
+func (r OAuthRetryPolicy) Backoff(attempt int) time.Duration {
+	delay := r.BaseDelay << attempt
+	if delay <= 0 || delay > r.MaxDelay {
+		delay = r.MaxDelay
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+
+	// full jitter spreads out clients which failed at the same moment
+	return rand.N(delay) + 1
+}
+
+func RetryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	val := header.Get("Retry-After")
+	if len(val) < 1 {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(val); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if at, err := http.ParseTime(val); err == nil {
+		if wait := at.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+func isConnectError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+func (r OAuthRetryPolicy) shouldRetry(opts *oAuthRequestOptions, res *OAuthResponse, err error) bool {
+	safe := opts.Retry == RetrySafe || (opts.Retry == RetryAuto && isIdempotentMethod(opts.Method))
+
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, errResponseTooLarge) {
+			return false
+		}
+
+		return safe || isConnectError(err)
+	}
+
+	return safe && isRetryableStatus(res.StatusCode)
+}
+
+func (r OAuthRetryPolicy) wait(ctx context.Context, attempt int, res *OAuthResponse) bool {
+	delay := r.Backoff(attempt)
+	if res != nil {
+		if after, ok := RetryAfter(res.Header, time.Now()); ok {
+			// a provider asking for more patience than we allow means giving up now
+			if r.MaxDelay > 0 && after > r.MaxDelay {
+				return false
+			}
+			delay = after
+		}
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -1,7 +1,11 @@
 package oauth
 
 import (
+	"context"
+	"crypto/subtle"
 	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/DecxBase/core/types"
 	"github.com/DecxBase/core/utils"
@@ -31,6 +35,18 @@ func (s OAuthService[T]) GetProvider(name T) (OAuthServiceProvider, error) {
 	return val, nil
 }
 
+func (s OAuthService[T]) providerKey(name T) string {
+	return fmt.Sprintf("%v", name)
+}
+
+func (s OAuthService[T]) withClient(ctx context.Context, opts *oAuthOptions) context.Context {
+	if opts == nil || opts.HTTPClient == nil || ctx.Value(oAuthClientKey{}) != nil {
+		return ctx
+	}
+
+	return WithHTTPClient(ctx, opts.HTTPClient)
+}
+
 func (s OAuthService[T]) makeOptionCallbacks(cbs []OAuthOptionCallback) []OAuthOptionCallback {
 	optsCBs := append(make([]OAuthOptionCallback, 0), WithOptions(s.Options))
 	optsCBs = append(optsCBs, cbs...)
@@ -39,71 +55,380 @@ func (s OAuthService[T]) makeOptionCallbacks(cbs []OAuthOptionCallback) []OAuthO
 }
 
 func (s OAuthService[T]) Initialize(name T, scopes []string, cbs ...OAuthOptionCallback) (*OAuthRequestResult, error) {
+	return s.InitializeContext(context.Background(), name, scopes, cbs...)
+}
+
+func (s OAuthService[T]) InitializeContext(ctx context.Context, name T, scopes []string, cbs ...OAuthOptionCallback) (*OAuthRequestResult, error) {
 	service, err := s.GetProvider(name)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err := service.Initialize(Options(s.makeOptionCallbacks(cbs)...), scopes...)
-	if err != nil {
+	opts := Options(s.makeOptionCallbacks(cbs)...)
+	ctx = s.withClient(ctx, opts)
+
+	if err := checkRedirectURI(opts.RedirectAllowlist, opts.Redirect); err != nil {
 		return nil, err
 	}
 
+	if err := checkReturnTo(opts.RedirectAllowlist, opts.ReturnTo); err != nil {
+		return nil, err
+	}
+
+	method := service.PKCEMethod()
+	if resolver, ok := service.(OAuthPKCEResolver); ok {
+		method, err = resolver.PKCEMethodContext(ctx)
+		if err != nil {
+			return nil, withErrorProvider(err, service.Name())
+		}
+	}
+
+	if len(method) > 0 {
+		if len(opts.CodeVerifier) < 1 {
+			pkce, err := GeneratePKCE(method)
+			if err != nil {
+				return nil, err
+			}
+
+			WithOptPKCE(pkce)(opts)
+		} else if len(opts.CodeChallenge) < 1 {
+			opts.CodeChallenge = PKCEChallenge(opts.CodeVerifier, method)
+			opts.CodeChallengeMethod = method
+		}
+	}
+
+	if len(opts.Nonce) < 1 {
+		opts.Nonce, err = RandomString(32)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// without a store there would be nothing to check the callback against
+	if opts.StateStore == nil {
+		return nil, OAuthErrorInvalidConfig("state_store")
+	}
+
+	keyer, deferred := service.(OAuthStateKeyer)
+	if !deferred {
+		value, err := RandomString(32)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.saveState(ctx, name, opts, scopes, value); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := service.InitializeContext(ctx, opts, scopes...)
+	if err != nil {
+		return nil, withErrorProvider(err, service.Name())
+	}
+
+	// the provider has put what it needs back on callback into the state data by now
+	if deferred {
+		if err := s.saveState(ctx, name, opts, scopes, keyer.StateKey(opts.StateData)); err != nil {
+			return nil, err
+		}
+	}
+
+	result.State = opts.State
+	result.Nonce = opts.Nonce
+	if len(method) > 0 {
+		result.CodeVerifier = opts.CodeVerifier
+	}
+
 	return result, nil
 }
 
+func (s OAuthService[T]) saveState(ctx context.Context, name T, opts *oAuthOptions, scopes []string, value string) error {
+	if len(value) < 1 {
+		return OAuthErrorStateMismatch()
+	}
+
+	state, err := opts.StateStore.Save(ctx, &OAuthState{
+		Value:        value,
+		Provider:     s.providerKey(name),
+		CodeVerifier: opts.CodeVerifier,
+		Nonce:        opts.Nonce,
+		ReturnTo:     opts.ReturnTo,
+		Scopes:       scopes,
+		Metadata:     opts.StateData,
+	})
+	if err != nil {
+		return err
+	}
+
+	opts.State = state
+	return nil
+}
+
+func (s OAuthService[T]) verifyState(ctx context.Context, name T, opts *oAuthOptions, value string) (*OAuthState, error) {
+	if opts.StateStore == nil {
+		return nil, OAuthErrorInvalidConfig("state_store")
+	}
+
+	if len(value) < 1 {
+		return nil, OAuthErrorStateMismatch()
+	}
+
+	// without one-time entries a state could be replayed until it expires, only the
+	// value bound to the browser tells the legitimate callback apart
+	if stateless, ok := opts.StateStore.(StatelessStateStore); ok && stateless.Stateless() && len(opts.State) < 1 {
+		return nil, OAuthErrorStateMismatch()
+	}
+
+	if len(opts.State) > 0 && subtle.ConstantTimeCompare([]byte(opts.State), []byte(value)) != 1 {
+		return nil, OAuthErrorStateMismatch()
+	}
+
+	state, err := opts.StateStore.Consume(ctx, value)
+	if err != nil {
+		return nil, err
+	}
+
+	if state.Provider != s.providerKey(name) {
+		return nil, OAuthErrorStateMismatch()
+	}
+
+	return state, nil
+}
+
 func (s OAuthService[T]) Callback(name T, data types.JSONStringData, cbs ...OAuthOptionCallback) (*OAuthToken, error) {
-	service, err := s.GetProvider(name)
+	return s.CallbackContext(context.Background(), name, data, cbs...)
+}
+
+func (s OAuthService[T]) CallbackContext(ctx context.Context, name T, data types.JSONStringData, cbs ...OAuthOptionCallback) (*OAuthToken, error) {
+	result, _, err := s.CallbackStateContext(ctx, name, data, cbs...)
 	if err != nil {
 		return nil, err
 	}
 
+	return result, nil
+}
+
+func (s OAuthService[T]) CallbackState(name T, data types.JSONStringData, cbs ...OAuthOptionCallback) (*OAuthToken, *OAuthState, error) {
+	return s.CallbackStateContext(context.Background(), name, data, cbs...)
+}
+
+func (s OAuthService[T]) CallbackStateContext(ctx context.Context, name T, data types.JSONStringData, cbs ...OAuthOptionCallback) (*OAuthToken, *OAuthState, error) {
+	service, err := s.GetProvider(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	err = service.Validate(data)
+	if err != nil {
+		return nil, nil, withErrorProvider(err, service.Name())
+	}
+
+	opts := Options(s.makeOptionCallbacks(cbs)...)
+	ctx = s.withClient(ctx, opts)
+
+	if err := checkRedirectURI(opts.RedirectAllowlist, opts.Redirect); err != nil {
+		return nil, nil, err
+	}
+
+	// providers read callback fields such as `code` from the config
+	for key, val := range data {
+		if _, ok := opts.Config[key]; !ok && len(val) > 0 {
+			opts.Config[key] = val
+		}
+	}
+
+	value := data["state"]
+	keyer, deferred := service.(OAuthStateKeyer)
+	if deferred {
+		// the browser-bound value when there is one, the callback's own key otherwise
+		value = keyer.StateKey(data)
+		if len(opts.State) > 0 {
+			value = opts.State
+		}
+	}
+
+	state, err := s.verifyState(ctx, name, opts, value)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if deferred {
+		key := keyer.StateKey(data)
+		if len(key) < 1 || subtle.ConstantTimeCompare([]byte(keyer.StateKey(state.Metadata)), []byte(key)) != 1 {
+			return nil, nil, OAuthErrorStateMismatch()
+		}
+	}
+
+	// the allowlist may have been tightened since the state was issued
+	if err := checkReturnTo(opts.RedirectAllowlist, state.ReturnTo); err != nil {
+		return nil, nil, err
+	}
+
+	if len(opts.CodeVerifier) < 1 {
+		opts.CodeVerifier = state.CodeVerifier
+	}
+
+	if len(opts.Nonce) < 1 {
+		opts.Nonce = state.Nonce
+	}
+
+	for key, val := range state.Metadata {
+		if _, ok := opts.StateData[key]; !ok {
+			opts.StateData[key] = val
+		}
+	}
+
+	result, err := service.CallbackContext(ctx, opts)
+	if err != nil {
+		return nil, nil, withErrorProvider(err, service.Name())
+	}
+
+	// RFC 6749 section 5.1, an omitted scope means the requested ones were granted
+	if len(result.Scope) < 1 && len(state.Scopes) > 0 {
+		result.Scope = ParseScopes(strings.Join(state.Scopes, " "))
+	}
+
+	// the only full check an ID token gets, TokenToUser skips `exp` on stored tokens
+	if len(result.IDToken) > 0 {
+		_, err = service.VerifyIDTokenContext(ctx, result.IDToken, opts.Nonce)
+		if err != nil {
+			return nil, nil, withErrorProvider(err, service.Name())
+		}
+	}
+
+	if opts.TokenStore != nil {
+		user, err := service.TokenToUserContext(ctx, result)
+		if err != nil {
+			return nil, nil, withErrorProvider(err, service.Name())
+		}
+
+		err = opts.TokenStore.Save(ctx, TokenRecord(s.providerKey(name), user, result))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return result, state, nil
+}
+
+func (s OAuthService[T]) RefreshToken(name T, token string, cbs ...OAuthOptionCallback) (*OAuthToken, error) {
+	return s.RefreshTokenContext(context.Background(), name, token, cbs...)
+}
+
+// RefreshTokenContext updates the stored record of `token` in the same store Callback
+// saved it to, pass the same WithOptTokenStore when it isn't set on the service
+func (s OAuthService[T]) RefreshTokenContext(ctx context.Context, name T, token string, cbs ...OAuthOptionCallback) (*OAuthToken, error) {
+	opts := Options(s.makeOptionCallbacks(cbs)...)
+	ctx = s.withClient(ctx, opts)
+
+	service, err := s.GetProvider(name)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err := service.Callback(Options(s.makeOptionCallbacks(cbs)...))
+	result, err := service.RefreshTokenContext(ctx, token)
 	if err != nil {
+		return nil, withErrorProvider(err, service.Name())
+	}
+
+	if err := s.storeRefreshed(ctx, opts.TokenStore, name, token, result); err != nil {
 		return nil, err
 	}
 
 	return result, nil
 }
 
-func (s OAuthService[T]) RefreshToken(name T, token string) (*OAuthToken, error) {
+// storeRefreshed replaces the stored token the refresh token was issued with, if any
+func (s OAuthService[T]) storeRefreshed(ctx context.Context, store TokenStore, name T, previous string, token *OAuthToken) error {
+	if store == nil {
+		return nil
+	}
+
+	record, err := store.FindByRefreshToken(ctx, s.providerKey(name), previous)
+	if OAuthErrorReason(err) == "token_not_found" {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(token.RefreshToken) < 1 {
+		token.RefreshToken = previous
+	}
+	record.Token = token
+
+	return store.Save(ctx, record)
+}
+
+func (s OAuthService[T]) TokenSource(name T, token *OAuthToken, cbs ...OAuthTokenSourceCallback) *oAuthTokenSource {
+	return TokenSource(token, func(ctx context.Context, refreshToken string) (*OAuthToken, error) {
+		return s.RefreshTokenContext(ctx, name, refreshToken)
+	}, cbs...)
+}
+
+func (s OAuthService[T]) Client(ctx context.Context, name T, token *OAuthToken, cbs ...OAuthTokenSourceCallback) (*http.Client, error) {
 	service, err := s.GetProvider(name)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err := service.RefreshToken(token)
+	ctx = s.withClient(ctx, s.Options)
+	base := HTTPClientFromContext(ctx)
+
+	return &http.Client{
+		Transport:     Transport(service, s.TokenSource(name, token, cbs...), base.Transport),
+		CheckRedirect: base.CheckRedirect,
+		Jar:           base.Jar,
+		Timeout:       base.Timeout,
+	}, nil
+}
+
+func (s OAuthService[T]) RevokeToken(name T, token string) error {
+	return s.RevokeTokenContext(context.Background(), name, token)
+}
+
+func (s OAuthService[T]) RevokeTokenContext(ctx context.Context, name T, token string) error {
+	ctx = s.withClient(ctx, s.Options)
+
+	service, err := s.GetProvider(name)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return result, nil
+	return withErrorProvider(service.RevokeTokenContext(ctx, token), service.Name())
 }
 
 func (s OAuthService[T]) TokenToUser(name T, token *OAuthToken) (*OAuthUser, error) {
+	return s.TokenToUserContext(context.Background(), name, token)
+}
+
+func (s OAuthService[T]) TokenToUserContext(ctx context.Context, name T, token *OAuthToken) (*OAuthUser, error) {
+	ctx = s.withClient(ctx, s.Options)
+
 	service, err := s.GetProvider(name)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err := service.TokenToUser(token)
+	result, err := service.TokenToUserContext(ctx, token)
 	if err != nil {
-		return nil, err
+		return nil, withErrorProvider(err, service.Name())
 	}
 
 	return result, nil
 }
 
 func (s OAuthService[T]) Get(name T, token string, fields []string, opts *oAuthOptions) (types.JSONDumpData, error) {
+	return s.GetContext(context.Background(), name, token, fields, opts)
+}
+
+func (s OAuthService[T]) GetContext(ctx context.Context, name T, token string, fields []string, opts *oAuthOptions) (types.JSONDumpData, error) {
 	if len(fields) < 1 {
 		return make(types.JSONDumpData), nil
 	}
 
+	ctx = s.withClient(ctx, s.Options)
+
 	service, err := s.GetProvider(name)
 	if err != nil {
 		return nil, err
@@ -119,9 +444,9 @@ func (s OAuthService[T]) Get(name T, token string, fields []string, opts *oAuthO
 		return field
 	})
 
-	result, err := service.Get(token, theFields, opts)
+	result, err := service.GetContext(ctx, token, theFields, opts)
 	if err != nil {
-		return nil, err
+		return nil, withErrorProvider(err, service.Name())
 	}
 
 	newResult := make(types.JSONDumpData)
@@ -137,14 +462,20 @@ func (s OAuthService[T]) Get(name T, token string, fields []string, opts *oAuthO
 }
 
 func (s OAuthService[T]) GetRaw(name T, token string, fields []string, opts *oAuthOptions) (types.JSONDumpData, error) {
+	return s.GetRawContext(context.Background(), name, token, fields, opts)
+}
+
+func (s OAuthService[T]) GetRawContext(ctx context.Context, name T, token string, fields []string, opts *oAuthOptions) (types.JSONDumpData, error) {
+	ctx = s.withClient(ctx, s.Options)
+
 	service, err := s.GetProvider(name)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err := service.Get(token, fields, opts)
+	result, err := service.GetContext(ctx, token, fields, opts)
 	if err != nil {
-		return nil, err
+		return nil, withErrorProvider(err, service.Name())
 	}
 
 	return result, nil
@@ -152,8 +483,13 @@ func (s OAuthService[T]) GetRaw(name T, token string, fields []string, opts *oAu
 
 func Service[T comparable](opts ...*oAuthOptions) *OAuthService[T] {
 	opt := Options()
-	if len(opts) > 0 {
-		opt = opts[0]
+	if len(opts) > 0 && opts[0] != nil {
+		// the caller's options stay untouched
+		opt = Options(WithOptions(opts[0]))
+	}
+
+	if opt.StateStore == nil {
+		opt.StateStore = MemoryStateStore(DefaultStateTTL)
 	}
 
 	return &OAuthService[T]{
@@ -0,0 +1,148 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DecxBase/core/types"
+)
+
+// stubOAuthProvider redirects to a fake authorize endpoint and grants any `good` code,
+// along with the ID token `idToken` returns when set
+type stubOAuthProvider struct {
+	*OAuthProviderBase
+	idToken func(*oAuthOptions) string
+}
+
+func (p stubOAuthProvider) Name() string {
+	return "stub"
+}
+
+func (p stubOAuthProvider) InitializeContext(ctx context.Context, opts *oAuthOptions, scopes ...string) (*OAuthRequestResult, error) {
+	uri := URIHost("idp.test").Join("authorize").
+		Set("redirect_uri", opts.Redirect).
+		Set("state", opts.State)
+
+	return &OAuthRequestResult{
+		Type: OAuthRequestRedirect,
+		Data: uri.String(),
+	}, nil
+}
+
+func (p stubOAuthProvider) CallbackContext(ctx context.Context, opts *oAuthOptions) (*OAuthToken, error) {
+	if code, _ := opts.GetConfig("code").(string); code != "good" {
+		return nil, OAuthErrorAccessDenied()
+	}
+
+	token := &OAuthToken{AccessToken: "access", TokenType: "Bearer"}
+	if p.idToken != nil {
+		token.IDToken = p.idToken(opts)
+	}
+
+	return token, nil
+}
+
+func (p stubOAuthProvider) TokenToUserContext(ctx context.Context, token *OAuthToken) (*OAuthUser, error) {
+	return &OAuthUser{UserID: "1", IdentityType: "id", Identity: "1", AccessToken: token.AccessToken}, nil
+}
+
+func stubService(store StateStore) *OAuthService[string] {
+	return Service[string](Options(WithOptStateStore(store))).
+		Register("stub", stubOAuthProvider{OAuthProviderBase: &OAuthProviderBase{}})
+}
+
+func TestCallbackState(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	tests := []struct {
+		name    string
+		store   StateStore
+		sent    string // the callback's `state`, the issued one when empty
+		browser string // the WithOptState value, the issued one when empty, none for "-"
+		replay  bool
+		wait    time.Duration
+		code    string
+	}{
+		{name: "memory", store: MemoryStateStore(0)},
+		{name: "memory without browser state", store: MemoryStateStore(0), browser: "-"},
+		{name: "memory forged", store: MemoryStateStore(0), sent: "forged", browser: "-", code: "mismatch"},
+		{name: "memory other browser", store: MemoryStateStore(0), browser: "other", code: "mismatch"},
+		{name: "memory replayed", store: MemoryStateStore(0), replay: true, code: "replayed"},
+		{name: "memory expired", store: MemoryStateStore(time.Millisecond), wait: 5 * time.Millisecond, code: "expired"},
+		{name: "cookie", store: CookieStateStore(secret, 0)},
+		{name: "cookie without browser state", store: CookieStateStore(secret, 0), browser: "-", code: "mismatch"},
+		{name: "cookie forged", store: CookieStateStore(secret, 0), sent: "forged", browser: "forged", code: "mismatch"},
+		{name: "cookie other browser", store: CookieStateStore(secret, 0), browser: "other", code: "mismatch"},
+		{name: "cookie expired", store: CookieStateStore(secret, time.Millisecond), wait: 5 * time.Millisecond, code: "expired"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := stubService(tt.store)
+
+			result, err := srv.Initialize("stub", nil, WithOptRedirect("https://app.test/cb"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sent := result.State
+			if len(tt.sent) > 0 {
+				sent = tt.sent
+			}
+
+			var cbs []OAuthOptionCallback
+			switch tt.browser {
+			case "-":
+			case "":
+				cbs = append(cbs, WithOptState(result.State))
+			default:
+				cbs = append(cbs, WithOptState(tt.browser))
+			}
+
+			time.Sleep(tt.wait)
+
+			data := types.JSONStringData{"code": "good", "state": sent}
+			_, err = srv.Callback("stub", data, cbs...)
+			if tt.replay {
+				if err != nil {
+					t.Fatalf("first callback failed: %v", err)
+				}
+				_, err = srv.Callback("stub", data, cbs...)
+			}
+
+			if len(tt.code) < 1 {
+				if err != nil {
+					t.Fatalf("callback failed: %v", err)
+				}
+				return
+			}
+
+			var oauthErr OAuthError
+			if !errors.As(err, &oauthErr) || oauthErr.Reason != "invalid_state" || oauthErr.Code != tt.code {
+				t.Fatalf("callback error = %v, want invalid_state %s", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestStateStoreRequired(t *testing.T) {
+	srv := &OAuthService[string]{
+		Options:   Options(),
+		Providers: map[string]OAuthServiceProvider{"stub": stubOAuthProvider{OAuthProviderBase: &OAuthProviderBase{}}},
+	}
+
+	if _, err := srv.Initialize("stub", nil); OAuthErrorReason(err) != "invalid_config" {
+		t.Errorf("initialize error = %v, want invalid_config", err)
+	}
+
+	data := types.JSONStringData{"code": "good", "state": "anything"}
+	if _, err := srv.Callback("stub", data); OAuthErrorReason(err) != "invalid_config" {
+		t.Errorf("callback error = %v, want invalid_config", err)
+	}
+
+	if _, err := stubService(MemoryStateStore(0)).Callback("stub", data, WithOptStateStore(nil)); OAuthErrorReason(err) != "invalid_config" {
+		t.Errorf("callback with a nil store error = %v, want invalid_config", err)
+	}
+}
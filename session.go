@@ -0,0 +1,474 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/DecxBase/core/types"
+)
+
+const (
+	DefaultSessionCookie   = "oauth_session"
+	DefaultSessionIdle     = 30 * time.Minute
+	DefaultSessionAbsolute = 24 * time.Hour
+)
+
+type OAuthSession struct {
+	ID        string             `json:"id"`
+	User      *OAuthUser         `json:"user"`
+	TokenKey  OAuthTokenKey      `json:"token_key"`
+	Scope     OAuthScopes        `json:"scope,omitempty"`
+	Claims    types.JSONDumpData `json:"claims,omitempty"`
+	Data      map[string]string  `json:"data,omitempty"`
+	AuthTime  time.Time          `json:"auth_time"`
+	LastSeen  time.Time          `json:"last_seen"`
+	ExpiresAt time.Time          `json:"expires_at"`
+}
+
+func (s OAuthSession) Provider() string {
+	return s.TokenKey.Provider
+}
+
+// clone copies the maps as well, so stored sessions never share them with callers
+func (s OAuthSession) clone() *OAuthSession {
+	copied := s
+
+	if s.User != nil {
+		user := *s.User
+		copied.User = &user
+	}
+
+	if s.Scope != nil {
+		copied.Scope = make(OAuthScopes, len(s.Scope))
+		for scope := range s.Scope {
+			copied.Scope[scope] = struct{}{}
+		}
+	}
+
+	if s.Claims != nil {
+		copied.Claims = make(types.JSONDumpData, len(s.Claims))
+		for key, val := range s.Claims {
+			copied.Claims[key] = val
+		}
+	}
+
+	if s.Data != nil {
+		copied.Data = make(map[string]string, len(s.Data))
+		for key, val := range s.Data {
+			copied.Data[key] = val
+		}
+	}
+
+	return &copied
+}
+
+type SessionStore interface {
+	Save(context.Context, *OAuthSession) error
+	Load(context.Context, string) (*OAuthSession, error)
+	Delete(context.Context, string) error
+	// DeleteUser revokes every session of a user, e.g. after a password change upstream
+	DeleteUser(ctx context.Context, provider string, userID string) error
+}
+
+type memorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*OAuthSession
+	now      func() time.Time
+}
+
+func (m *memorySessionStore) Save(ctx context.Context, session *OAuthSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	for id, entry := range m.sessions {
+		if now.After(entry.ExpiresAt) {
+			delete(m.sessions, id)
+		}
+	}
+
+	m.sessions[session.ID] = session.clone()
+
+	return nil
+}
+
+func (m *memorySessionStore) Load(ctx context.Context, id string) (*OAuthSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session := m.sessions[id]
+	if session == nil {
+		return nil, OAuthErrorSessionMissing()
+	}
+
+	return session.clone(), nil
+}
+
+func (m *memorySessionStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *memorySessionStore) DeleteUser(ctx context.Context, provider string, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, session := range m.sessions {
+		if session.TokenKey.Provider == provider && session.TokenKey.UserID == userID {
+			delete(m.sessions, id)
+		}
+	}
+
+	return nil
+}
+
+func MemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{
+		sessions: make(map[string]*OAuthSession),
+		now:      time.Now,
+	}
+}
+
+type oAuthSessionCookie struct {
+	ID        string `json:"i"`
+	ExpiresAt int64  `json:"e"`
+}
+
+type oAuthSessionManager struct {
+	store    SessionStore
+	key      []byte
+	cookie   string
+	path     string
+	domain   string
+	secure   bool
+	sameSite http.SameSite
+	idle     time.Duration
+	absolute time.Duration
+	now      func() time.Time
+}
+
+type OAuthSessionCallback = func(*oAuthSessionManager)
+
+func WithSessionStore(store SessionStore) OAuthSessionCallback {
+	return func(m *oAuthSessionManager) {
+		m.store = store
+	}
+}
+
+func WithSessionCookie(name string, path string, domain string) OAuthSessionCallback {
+	return func(m *oAuthSessionManager) {
+		m.cookie = name
+		m.path = path
+		m.domain = domain
+	}
+}
+
+// WithSessionInsecure allows the cookie over plain http, meant for local development
+func WithSessionInsecure() OAuthSessionCallback {
+	return func(m *oAuthSessionManager) {
+		m.secure = false
+	}
+}
+
+func WithSessionSameSite(mode http.SameSite) OAuthSessionCallback {
+	return func(m *oAuthSessionManager) {
+		m.sameSite = mode
+	}
+}
+
+// WithSessionTimeouts sets how long a session survives without requests and how long it lives at most
+func WithSessionTimeouts(idle time.Duration, absolute time.Duration) OAuthSessionCallback {
+	return func(m *oAuthSessionManager) {
+		m.idle = idle
+		m.absolute = absolute
+	}
+}
+
+// WithSessionClock replaces time.Now for expiry checks, a MemorySessionStore prunes on it too
+func WithSessionClock(now func() time.Time) OAuthSessionCallback {
+	return func(m *oAuthSessionManager) {
+		m.now = now
+	}
+}
+
+// cookieExpiry follows the idle timeout, capped by the absolute one
+func (m *oAuthSessionManager) cookieExpiry(session *OAuthSession) time.Time {
+	if m.idle > 0 && session.LastSeen.Add(m.idle).Before(session.ExpiresAt) {
+		return session.LastSeen.Add(m.idle)
+	}
+
+	return session.ExpiresAt
+}
+
+func (m *oAuthSessionManager) setCookie(w http.ResponseWriter, session *OAuthSession) error {
+	expiresAt := m.cookieExpiry(session)
+
+	payload, err := json.Marshal(oAuthSessionCookie{
+		ID:        session.ID,
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	value, err := sealData(m.key, payload, []byte(m.cookie))
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.cookie,
+		Value:    value,
+		Path:     m.path,
+		Domain:   m.domain,
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   m.secure,
+		SameSite: m.sameSite,
+	})
+
+	return nil
+}
+
+func (m *oAuthSessionManager) clearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.cookie,
+		Path:     m.path,
+		Domain:   m.domain,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   m.secure,
+		SameSite: m.sameSite,
+	})
+}
+
+func (m *oAuthSessionManager) readCookie(r *http.Request) (*oAuthSessionCookie, error) {
+	cookie, err := r.Cookie(m.cookie)
+	if err != nil {
+		return nil, OAuthErrorSessionMissing()
+	}
+
+	payload, err := openData(m.key, cookie.Value, []byte(m.cookie))
+	if err != nil {
+		return nil, OAuthErrorSessionMissing()
+	}
+
+	var data oAuthSessionCookie
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return nil, OAuthErrorSessionMissing()
+	}
+
+	if m.now().After(time.Unix(data.ExpiresAt, 0)) {
+		return nil, OAuthErrorSessionExpired()
+	}
+
+	return &data, nil
+}
+
+// Create starts a session for a freshly authenticated user, `token` is only referenced
+// through its TokenStore key and isn't part of the session
+func (m *oAuthSessionManager) Create(w http.ResponseWriter, r *http.Request, provider string, user *OAuthUser, token *OAuthToken) (*OAuthSession, error) {
+	// a new login never inherits a session id the browser already had
+	if previous, err := m.readCookie(r); err == nil {
+		m.store.Delete(r.Context(), previous.ID)
+	}
+
+	id, err := RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	// the access token stays in the TokenStore, sessions only point at it
+	profile := *user
+	profile.AccessToken = ""
+
+	now := m.now()
+	session := &OAuthSession{
+		ID:   id,
+		User: &profile,
+		TokenKey: OAuthTokenKey{
+			Provider: provider,
+			UserID:   user.UserID,
+			Identity: user.Identity,
+		},
+		Data:      make(map[string]string),
+		AuthTime:  now,
+		LastSeen:  now,
+		ExpiresAt: now.Add(m.absolute),
+	}
+
+	if token != nil {
+		session.Scope = token.Scope
+
+		// the callback has verified the ID token already
+		if jwt, err := ParseJWT(token.IDToken); err == nil {
+			session.Claims = jwt.Claims
+		}
+	}
+
+	if err := m.store.Save(r.Context(), session); err != nil {
+		return nil, err
+	}
+
+	if err := m.setCookie(w, session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// Get resolves the request's session without renewing it
+func (m *oAuthSessionManager) Get(r *http.Request) (*OAuthSession, error) {
+	data, err := m.readCookie(r)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := m.store.Load(r.Context(), data.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.now()
+	if now.After(session.ExpiresAt) || (m.idle > 0 && now.Sub(session.LastSeen) > m.idle) {
+		m.store.Delete(r.Context(), session.ID)
+		return nil, OAuthErrorSessionExpired()
+	}
+
+	return session, nil
+}
+
+// Touch slides the idle timeout forward and reissues the cookie with it, writes are skipped
+// while the session was seen recently
+func (m *oAuthSessionManager) Touch(w http.ResponseWriter, r *http.Request, session *OAuthSession) error {
+	now := m.now()
+	if now.Sub(session.LastSeen) < m.idle/10 {
+		return nil
+	}
+
+	session.LastSeen = now
+	if err := m.store.Save(r.Context(), session); err != nil {
+		return err
+	}
+
+	return m.setCookie(w, session)
+}
+
+func (m *oAuthSessionManager) Save(ctx context.Context, session *OAuthSession) error {
+	return m.store.Save(ctx, session)
+}
+
+func (m *oAuthSessionManager) Destroy(w http.ResponseWriter, r *http.Request) error {
+	m.clearCookie(w)
+
+	data, err := m.readCookie(r)
+	if err != nil {
+		return nil
+	}
+
+	return m.store.Delete(r.Context(), data.ID)
+}
+
+func (m *oAuthSessionManager) Revoke(ctx context.Context, id string) error {
+	return m.store.Delete(ctx, id)
+}
+
+func (m *oAuthSessionManager) RevokeUser(ctx context.Context, provider string, userID string) error {
+	return m.store.DeleteUser(ctx, provider, userID)
+}
+
+// Middleware loads the session, if any, into the request context, it never rejects a request
+func (m *oAuthSessionManager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, err := m.Get(r)
+		if err != nil {
+			if _, cerr := r.Cookie(m.cookie); cerr == nil {
+				m.clearCookie(w)
+			}
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err := m.Touch(w, r, session); err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithSession(r.Context(), session)))
+	})
+}
+
+// HandlerSuccess starts a session after the login handlers succeed and redirects to the URL
+// RequireAuth remembered, or `target` otherwise
+func (m *oAuthSessionManager) HandlerSuccess(target string) OAuthHandlerSuccess {
+	return func(w http.ResponseWriter, r *http.Request, token *OAuthToken, user *OAuthUser, state *OAuthState) {
+		provider := r.PathValue("provider")
+		if state != nil {
+			provider = state.Provider
+		}
+
+		if _, err := m.Create(w, r, provider, user, token); err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		// `target` is shared by every request, the destination is resolved per request
+		http.Redirect(w, r, state.Destination(target), http.StatusFound)
+	}
+}
+
+type oAuthSessionKey struct{}
+
+func WithSession(ctx context.Context, session *OAuthSession) context.Context {
+	return context.WithValue(ctx, oAuthSessionKey{}, session)
+}
+
+func SessionFromContext(ctx context.Context) *OAuthSession {
+	session, _ := ctx.Value(oAuthSessionKey{}).(*OAuthSession)
+	return session
+}
+
+func UserFromContext(ctx context.Context) *OAuthUser {
+	if session := SessionFromContext(ctx); session != nil {
+		return session.User
+	}
+
+	return nil
+}
+
+func SessionManager(secret []byte, cbs ...OAuthSessionCallback) *oAuthSessionManager {
+	manager := &oAuthSessionManager{
+		key:      deriveKey(secret),
+		cookie:   DefaultSessionCookie,
+		path:     "/",
+		secure:   true,
+		sameSite: http.SameSiteLaxMode,
+		idle:     DefaultSessionIdle,
+		absolute: DefaultSessionAbsolute,
+		now:      time.Now,
+	}
+
+	for _, cb := range cbs {
+		cb(manager)
+	}
+
+	if manager.store == nil {
+		manager.store = MemorySessionStore()
+	}
+
+	// the memory store prunes on the manager's clock, so both agree on what has expired
+	if memory, ok := manager.store.(*memorySessionStore); ok {
+		memory.mu.Lock()
+		memory.now = manager.now
+		memory.mu.Unlock()
+	}
+
+	return manager
+}
@@ -0,0 +1,41 @@
+package oauth
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemorySessionStoreClock(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := MemorySessionStore()
+	manager := SessionManager([]byte("0123456789abcdef0123456789abcdef"),
+		WithSessionStore(store),
+		WithSessionTimeouts(time.Hour, 24*time.Hour),
+		WithSessionClock(func() time.Time { return now }),
+	)
+
+	create := func() *OAuthSession {
+		session, err := manager.Create(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), "stub", &OAuthUser{UserID: "1"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return session
+	}
+
+	first := create()
+
+	// still valid on the manager's clock, so the store keeps it however far time.Now is ahead
+	create()
+	if _, err := store.Load(context.Background(), first.ID); err != nil {
+		t.Fatalf("session pruned before it expired: %v", err)
+	}
+
+	now = now.Add(25 * time.Hour)
+	create()
+	if _, err := store.Load(context.Background(), first.ID); OAuthErrorReason(err) != "invalid_session" {
+		t.Fatalf("expired session kept, error = %v", err)
+	}
+}
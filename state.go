@@ -0,0 +1,179 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+const DefaultStateTTL = 10 * time.Minute
+
+type OAuthState struct {
+	Value        string            `json:"v"`
+	Provider     string            `json:"p"`
+	CodeVerifier string            `json:"cv,omitempty"`
+	Nonce        string            `json:"n,omitempty"`
+	ReturnTo     string            `json:"r,omitempty"`
+	Scopes       []string          `json:"s,omitempty"`
+	Metadata     map[string]string `json:"m,omitempty"`
+	ExpiresAt    time.Time         `json:"e"`
+}
+
+func (s OAuthState) Get(key string) string {
+	return s.Metadata[key]
+}
+
+// Destination is where to send the user after login, the state's own return_to, which was
+// validated against the allowlist before it was sealed, or `fallback`
+func (s *OAuthState) Destination(fallback string) string {
+	if s != nil && len(s.ReturnTo) > 0 {
+		return s.ReturnTo
+	}
+
+	return fallback
+}
+
+func (s OAuthState) Expired(now time.Time) bool {
+	return !s.ExpiresAt.IsZero() && now.After(s.ExpiresAt)
+}
+
+type StateStore interface {
+	// Save persists the state and returns the value to send as the `state` parameter
+	Save(context.Context, *OAuthState) (string, error)
+	// Consume resolves a `state` parameter exactly once
+	Consume(context.Context, string) (*OAuthState, error)
+}
+
+// StatelessStateStore is implemented by stores which can't remember consumed values,
+// callbacks through them are refused unless the expected state is passed via WithOptState
+type StatelessStateStore interface {
+	StateStore
+	Stateless() bool
+}
+
+type memoryStateEntry struct {
+	state *OAuthState
+	used  bool
+}
+
+type memoryStateStore struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]*memoryStateEntry
+}
+
+func (m *memoryStateStore) prune(now time.Time) {
+	for key, entry := range m.entries {
+		if entry.state.Expired(now) {
+			delete(m.entries, key)
+		}
+	}
+}
+
+func (m *memoryStateStore) Save(ctx context.Context, state *OAuthState) (string, error) {
+	now := time.Now()
+	if state.ExpiresAt.IsZero() {
+		state.ExpiresAt = now.Add(m.ttl)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.prune(now)
+	m.entries[state.Value] = &memoryStateEntry{state: state}
+
+	return state.Value, nil
+}
+
+func (m *memoryStateStore) Consume(ctx context.Context, value string) (*OAuthState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := m.entries[value]
+	if entry == nil {
+		return nil, OAuthErrorStateMismatch()
+	}
+
+	if entry.state.Expired(time.Now()) {
+		delete(m.entries, value)
+		return nil, OAuthErrorStateExpired()
+	}
+
+	// used entries are kept until they expire so replays can be told apart from forgeries
+	if entry.used {
+		return nil, OAuthErrorStateReplayed()
+	}
+	entry.used = true
+
+	// callers get their own copy, nothing they change reaches the kept entry
+	state := *entry.state
+	return &state, nil
+}
+
+func MemoryStateStore(ttl time.Duration) *memoryStateStore {
+	if ttl <= 0 {
+		ttl = DefaultStateTTL
+	}
+
+	return &memoryStateStore{
+		ttl:     ttl,
+		entries: make(map[string]*memoryStateEntry),
+	}
+}
+
+// cookieStateStore keeps nothing server side, the whole state is sealed into the
+// `state` parameter itself. Since it can't remember consumed values, bind it to the
+// browser by storing the returned value in a cookie, passing it back through
+// WithOptState on callback and clearing the cookie afterwards.
+type cookieStateStore struct {
+	key []byte
+	ttl time.Duration
+}
+
+func (c cookieStateStore) Stateless() bool {
+	return true
+}
+
+func (c cookieStateStore) Save(ctx context.Context, state *OAuthState) (string, error) {
+	if state.ExpiresAt.IsZero() {
+		state.ExpiresAt = time.Now().Add(c.ttl)
+	}
+
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+
+	return sealData(c.key, payload, nil)
+}
+
+func (c cookieStateStore) Consume(ctx context.Context, value string) (*OAuthState, error) {
+	var state OAuthState
+
+	payload, err := openData(c.key, value, nil)
+	if err != nil {
+		return nil, OAuthErrorStateMismatch()
+	}
+
+	if err := json.Unmarshal(payload, &state); err != nil {
+		return nil, OAuthErrorStateMismatch()
+	}
+
+	if state.Expired(time.Now()) {
+		return nil, OAuthErrorStateExpired()
+	}
+
+	return &state, nil
+}
+
+func CookieStateStore(secret []byte, ttl time.Duration) *cookieStateStore {
+	if ttl <= 0 {
+		ttl = DefaultStateTTL
+	}
+
+	return &cookieStateStore{
+		key: deriveKey(secret),
+		ttl: ttl,
+	}
+}
@@ -0,0 +1,206 @@
+package oauth
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DecxBase/core/utils"
+)
+
+type OAuthScopes map[string]struct{}
+
+func ParseScopes(raw string) OAuthScopes {
+	scopes := make(OAuthScopes)
+
+	// most providers use spaces, a few (github, facebook) use commas
+	for _, scope := range strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ' ' || r == ','
+	}) {
+		scopes[scope] = struct{}{}
+	}
+
+	return scopes
+}
+
+func (s OAuthScopes) Has(scopes ...string) bool {
+	for _, scope := range scopes {
+		if _, ok := s[scope]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s OAuthScopes) List() []string {
+	list := make([]string, 0, len(s))
+	for scope := range s {
+		list = append(list, scope)
+	}
+	sort.Strings(list)
+
+	return list
+}
+
+func (s OAuthScopes) String() string {
+	return strings.Join(s.List(), " ")
+}
+
+func (s OAuthScopes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s *OAuthScopes) UnmarshalJSON(data []byte) error {
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*s = make(OAuthScopes)
+	switch val := raw.(type) {
+	case string:
+		*s = ParseScopes(val)
+	case []any:
+		for _, item := range val {
+			if scope, ok := item.(string); ok {
+				(*s)[scope] = struct{}{}
+			}
+		}
+	}
+
+	return nil
+}
+
+var oAuthTokenFields = []string{
+	"access_token",
+	"id_token",
+	"refresh_token",
+	"token_secret",
+	"expires_in",
+	"token_type",
+	"scope",
+	"expiry",
+}
+
+func (t OAuthToken) Valid() bool {
+	return len(t.AccessToken) > 0 && !t.Expired(0)
+}
+
+func (t OAuthToken) Expired(skew time.Duration) bool {
+	if t.Expiry.IsZero() {
+		return false
+	}
+
+	return time.Now().Add(skew).After(t.Expiry)
+}
+
+func (t OAuthToken) Extra(key string) any {
+	return t.Raw[key]
+}
+
+func (t OAuthToken) MarshalJSON() ([]byte, error) {
+	data := make(map[string]any, len(t.Raw)+len(oAuthTokenFields))
+	for key, val := range t.Raw {
+		data[key] = val
+	}
+
+	data["access_token"] = t.AccessToken
+	data["token_type"] = t.TokenType
+	data["expires_in"] = t.ExpiresIn
+
+	if len(t.IDToken) > 0 {
+		data["id_token"] = t.IDToken
+	}
+
+	if len(t.RefreshToken) > 0 {
+		data["refresh_token"] = t.RefreshToken
+	}
+
+	if len(t.TokenSecret) > 0 {
+		data["token_secret"] = t.TokenSecret
+	}
+
+	if len(t.Scope) > 0 {
+		data["scope"] = t.Scope
+	}
+
+	if !t.Expiry.IsZero() {
+		data["expiry"] = t.Expiry.Format(time.RFC3339Nano)
+	}
+
+	return json.Marshal(data)
+}
+
+func (t *OAuthToken) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	var result OAuthToken
+	for key, target := range map[string]*string{
+		"access_token":  &result.AccessToken,
+		"id_token":      &result.IDToken,
+		"refresh_token": &result.RefreshToken,
+		"token_secret":  &result.TokenSecret,
+		"token_type":    &result.TokenType,
+	} {
+		if val, ok := raw[key]; ok {
+			if err := json.Unmarshal(val, target); err != nil {
+				return err
+			}
+		}
+	}
+
+	if val, ok := raw["expires_in"]; ok {
+		// some providers send expires_in as a string
+		var expires any
+		if err := json.Unmarshal(val, &expires); err != nil {
+			return err
+		}
+
+		switch num := expires.(type) {
+		case float64:
+			result.ExpiresIn = int64(num)
+		case string:
+			result.ExpiresIn, _ = strconv.ParseInt(num, 10, 64)
+		}
+	}
+
+	if val, ok := raw["scope"]; ok {
+		if err := json.Unmarshal(val, &result.Scope); err != nil {
+			return err
+		}
+	}
+
+	if val, ok := raw["expiry"]; ok {
+		if err := json.Unmarshal(val, &result.Expiry); err != nil {
+			return err
+		}
+	} else if result.ExpiresIn > 0 {
+		// the token was just received, so pin its lifetime to an absolute time
+		result.Expiry = time.Now().Add(time.Duration(result.ExpiresIn) * time.Second)
+	}
+
+	for key, val := range raw {
+		if utils.CheckContains(oAuthTokenFields, key) {
+			continue
+		}
+
+		if result.Raw == nil {
+			result.Raw = make(map[string]any)
+		}
+
+		var extra any
+		if err := json.Unmarshal(val, &extra); err != nil {
+			return err
+		}
+		result.Raw[key] = extra
+	}
+
+	*t = result
+	return nil
+}
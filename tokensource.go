@@ -0,0 +1,154 @@
+package oauth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const DefaultRefreshSkew = time.Minute
+
+type OAuthTokenSource interface {
+	Token(context.Context) (*OAuthToken, error)
+}
+
+type OAuthTokenRefresher = func(context.Context, string) (*OAuthToken, error)
+
+type OAuthTokenSourceCallback = func(*oAuthTokenSource)
+
+type oAuthRefreshCall struct {
+	done  chan struct{}
+	token *OAuthToken
+	err   error
+}
+
+type oAuthTokenSource struct {
+	refresh OAuthTokenRefresher
+	notify  func(*OAuthToken)
+	skew    time.Duration
+
+	mu     sync.Mutex
+	token  *OAuthToken
+	flight *oAuthRefreshCall
+}
+
+func WithTokenSkew(skew time.Duration) OAuthTokenSourceCallback {
+	return func(s *oAuthTokenSource) {
+		s.skew = skew
+	}
+}
+
+func WithTokenNotify(notify func(*OAuthToken)) OAuthTokenSourceCallback {
+	return func(s *oAuthTokenSource) {
+		s.notify = notify
+	}
+}
+
+func (s *oAuthTokenSource) Token(ctx context.Context) (*OAuthToken, error) {
+	s.mu.Lock()
+	if s.token != nil && len(s.token.AccessToken) > 0 && !s.token.Expired(s.skew) {
+		token := s.token
+		s.mu.Unlock()
+
+		return token, nil
+	}
+
+	call, err := s.startRefresh(ctx)
+	s.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.waitRefresh(ctx, call)
+}
+
+// Refresh forces a refresh unless the token was already replaced since `stale` was handed out
+func (s *oAuthTokenSource) Refresh(ctx context.Context, stale *OAuthToken) (*OAuthToken, error) {
+	s.mu.Lock()
+	if stale != nil && s.token != nil && s.flight == nil && s.token.AccessToken != stale.AccessToken {
+		token := s.token
+		s.mu.Unlock()
+
+		return token, nil
+	}
+
+	call, err := s.startRefresh(ctx)
+	s.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.waitRefresh(ctx, call)
+}
+
+// startRefresh must be called with the lock held, concurrent callers share a single call
+func (s *oAuthTokenSource) startRefresh(ctx context.Context) (*oAuthRefreshCall, error) {
+	if s.flight != nil {
+		return s.flight, nil
+	}
+
+	if s.token == nil || len(s.token.RefreshToken) < 1 {
+		return nil, OAuthErrorTokenExpired()
+	}
+
+	call := &oAuthRefreshCall{
+		done: make(chan struct{}),
+	}
+	s.flight = call
+
+	// the refresh outlives any single waiter, so one cancelled caller doesn't fail the rest
+	go s.runRefresh(context.WithoutCancel(ctx), call, s.token)
+
+	return call, nil
+}
+
+func (s *oAuthTokenSource) runRefresh(ctx context.Context, call *oAuthRefreshCall, previous *OAuthToken) {
+	token, err := s.refresh(ctx, previous.RefreshToken)
+	if err == nil && token == nil {
+		err = OAuthErrorToken()
+	}
+
+	if err == nil && len(token.RefreshToken) < 1 {
+		// providers which don't rotate refresh tokens leave them out of the response
+		token.RefreshToken = previous.RefreshToken
+	}
+
+	s.mu.Lock()
+	if err == nil {
+		s.token = token
+	}
+	s.flight = nil
+	s.mu.Unlock()
+
+	if err == nil && s.notify != nil {
+		s.notify(token)
+	}
+
+	call.token, call.err = token, err
+	close(call.done)
+}
+
+func (s *oAuthTokenSource) waitRefresh(ctx context.Context, call *oAuthRefreshCall) (*OAuthToken, error) {
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TokenSource(token *OAuthToken, refresh OAuthTokenRefresher, cbs ...OAuthTokenSourceCallback) *oAuthTokenSource {
+	source := &oAuthTokenSource{
+		refresh: refresh,
+		skew:    DefaultRefreshSkew,
+		token:   token,
+	}
+
+	for _, cb := range cbs {
+		cb(source)
+	}
+
+	return source
+}
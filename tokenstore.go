@@ -0,0 +1,262 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+type OAuthTokenKey struct {
+	Provider string `json:"provider"`
+	UserID   string `json:"user_id"`
+	Identity string `json:"identity"`
+}
+
+type OAuthTokenRecord struct {
+	OAuthTokenKey
+	Token            *OAuthToken `json:"token"`
+	RefreshTokenHash string      `json:"refresh_token_hash,omitempty"`
+	CreatedAt        time.Time   `json:"created_at"`
+	UpdatedAt        time.Time   `json:"updated_at"`
+}
+
+// TokenHash fingerprints a refresh token so records can be found by it without storing it twice
+func TokenHash(token string) string {
+	if len(token) < 1 {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func TokenRecord(provider string, user *OAuthUser, token *OAuthToken) *OAuthTokenRecord {
+	return &OAuthTokenRecord{
+		OAuthTokenKey: OAuthTokenKey{
+			Provider: provider,
+			UserID:   user.UserID,
+			Identity: user.Identity,
+		},
+		Token: token,
+	}
+}
+
+func (r *OAuthTokenRecord) prepare(now time.Time) {
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = now
+	}
+	r.UpdatedAt = now
+
+	// sealed values come hashed already by the envelope store
+	if r.Token != nil && !isSealed(r.Token.RefreshToken) {
+		r.RefreshTokenHash = TokenHash(r.Token.RefreshToken)
+	}
+}
+
+func (r OAuthTokenRecord) clone() *OAuthTokenRecord {
+	if r.Token != nil {
+		token := *r.Token
+		r.Token = &token
+	}
+
+	return &r
+}
+
+type TokenStore interface {
+	Save(context.Context, *OAuthTokenRecord) error
+	Load(context.Context, OAuthTokenKey) (*OAuthTokenRecord, error)
+	Delete(context.Context, OAuthTokenKey) error
+	// List returns every record of a provider, narrowed to one user when userID is set
+	List(ctx context.Context, provider string, userID string) ([]*OAuthTokenRecord, error)
+	FindByRefreshToken(ctx context.Context, provider string, refreshToken string) (*OAuthTokenRecord, error)
+}
+
+type memoryTokenStore struct {
+	mu      sync.RWMutex
+	records map[OAuthTokenKey]*OAuthTokenRecord
+}
+
+func (m *memoryTokenStore) Save(ctx context.Context, record *OAuthTokenRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing := m.records[record.OAuthTokenKey]; existing != nil && record.CreatedAt.IsZero() {
+		record.CreatedAt = existing.CreatedAt
+	}
+
+	record.prepare(time.Now())
+	m.records[record.OAuthTokenKey] = record.clone()
+
+	return nil
+}
+
+func (m *memoryTokenStore) Load(ctx context.Context, key OAuthTokenKey) (*OAuthTokenRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	record := m.records[key]
+	if record == nil {
+		return nil, OAuthErrorTokenNotFound()
+	}
+
+	return record.clone(), nil
+}
+
+func (m *memoryTokenStore) Delete(ctx context.Context, key OAuthTokenKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.records, key)
+	return nil
+}
+
+func (m *memoryTokenStore) List(ctx context.Context, provider string, userID string) ([]*OAuthTokenRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]*OAuthTokenRecord, 0)
+	for key, record := range m.records {
+		if key.Provider == provider && (len(userID) < 1 || key.UserID == userID) {
+			result = append(result, record.clone())
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].UserID != result[j].UserID {
+			return result[i].UserID < result[j].UserID
+		}
+		return result[i].Identity < result[j].Identity
+	})
+
+	return result, nil
+}
+
+func (m *memoryTokenStore) FindByRefreshToken(ctx context.Context, provider string, refreshToken string) (*OAuthTokenRecord, error) {
+	hash := TokenHash(refreshToken)
+	if len(hash) < 1 {
+		return nil, OAuthErrorTokenNotFound()
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for key, record := range m.records {
+		if key.Provider == provider && record.RefreshTokenHash == hash {
+			return record.clone(), nil
+		}
+	}
+
+	return nil, OAuthErrorTokenNotFound()
+}
+
+func MemoryTokenStore() *memoryTokenStore {
+	return &memoryTokenStore{
+		records: make(map[OAuthTokenKey]*OAuthTokenRecord),
+	}
+}
+
+// fileTokenStore keeps every record in memory and rewrites the whole file on change,
+// which suits a single process holding a modest number of tokens
+type fileTokenStore struct {
+	*memoryTokenStore
+	path  string
+	write sync.Mutex
+}
+
+func (f *fileTokenStore) load() error {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	records := make([]*OAuthTokenRecord, 0)
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &records); err != nil {
+			return OAuthErrorDecodeFailed()
+		}
+	}
+
+	for _, record := range records {
+		f.records[record.OAuthTokenKey] = record
+	}
+
+	return nil
+}
+
+func (f *fileTokenStore) persist() error {
+	f.mu.RLock()
+	records := make([]*OAuthTokenRecord, 0, len(f.records))
+	for _, record := range f.records {
+		records = append(records, record)
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	f.mu.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	// write next to the target and rename so a crash never leaves a truncated file
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path)
+}
+
+func (f *fileTokenStore) Save(ctx context.Context, record *OAuthTokenRecord) error {
+	f.write.Lock()
+	defer f.write.Unlock()
+
+	if err := f.memoryTokenStore.Save(ctx, record); err != nil {
+		return err
+	}
+
+	return f.persist()
+}
+
+func (f *fileTokenStore) Delete(ctx context.Context, key OAuthTokenKey) error {
+	f.write.Lock()
+	defer f.write.Unlock()
+
+	if err := f.memoryTokenStore.Delete(ctx, key); err != nil {
+		return err
+	}
+
+	return f.persist()
+}
+
+func FileTokenStore(path string) (*fileTokenStore, error) {
+	store := &fileTokenStore{
+		memoryTokenStore: MemoryTokenStore(),
+		path:             path,
+	}
+
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
@@ -0,0 +1,212 @@
+package oauth
+
+import (
+	"context"
+)
+
+type envelopeTokenStore struct {
+	store     TokenStore
+	keyring   *oAuthKeyring
+	plaintext bool
+}
+
+type OAuthEnvelopeCallback = func(*envelopeTokenStore)
+
+// WithEnvelopePlaintext accepts values stored before encryption was enabled, meant for the
+// migration only: turn it on, run Reencrypt, then turn it off again
+func WithEnvelopePlaintext() OAuthEnvelopeCallback {
+	return func(e *envelopeTokenStore) {
+		e.plaintext = true
+	}
+}
+
+// fields returns the token values which are encrypted at rest
+func envelopeFields(token *OAuthToken) map[string]*string {
+	return map[string]*string{
+		"access_token":  &token.AccessToken,
+		"refresh_token": &token.RefreshToken,
+		"id_token":      &token.IDToken,
+		"token_secret":  &token.TokenSecret,
+	}
+}
+
+// additional binds a ciphertext to its record and field so values can't be swapped around
+func envelopeAdditional(key OAuthTokenKey, field string) []byte {
+	return []byte(key.Provider + "\x00" + key.UserID + "\x00" + key.Identity + "\x00" + field)
+}
+
+func (e envelopeTokenStore) seal(record *OAuthTokenRecord) (*OAuthTokenRecord, error) {
+	sealed := record.clone()
+	if sealed.Token == nil {
+		return sealed, nil
+	}
+
+	// hash the plaintext, the wrapped store can't see it anymore
+	sealed.RefreshTokenHash = TokenHash(record.Token.RefreshToken)
+
+	for field, val := range envelopeFields(sealed.Token) {
+		if len(*val) < 1 {
+			continue
+		}
+
+		data, err := e.keyring.Seal([]byte(*val), envelopeAdditional(record.OAuthTokenKey, field))
+		if err != nil {
+			return nil, err
+		}
+		*val = data
+	}
+
+	return sealed, nil
+}
+
+func (e envelopeTokenStore) open(record *OAuthTokenRecord) (*OAuthTokenRecord, error) {
+	if record.Token == nil {
+		return record, nil
+	}
+
+	for field, val := range envelopeFields(record.Token) {
+		if len(*val) < 1 {
+			continue
+		}
+
+		// anyone able to write the backing store could plant plaintext tokens otherwise
+		if !isSealed(*val) {
+			if e.plaintext {
+				continue
+			}
+
+			return nil, OAuthErrorDecryptFailed()
+		}
+
+		data, err := e.keyring.Open(*val, envelopeAdditional(record.OAuthTokenKey, field))
+		if err != nil {
+			return nil, err
+		}
+		*val = string(data)
+	}
+
+	return record, nil
+}
+
+// stale tells whether any value of the record is plaintext or sealed with an old key
+func (e envelopeTokenStore) stale(record *OAuthTokenRecord) bool {
+	if record.Token == nil {
+		return false
+	}
+
+	for _, val := range envelopeFields(record.Token) {
+		if len(*val) < 1 {
+			continue
+		}
+
+		kid, _, ok := splitSealed(*val)
+		if !ok || kid != e.keyring.Current() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e envelopeTokenStore) Save(ctx context.Context, record *OAuthTokenRecord) error {
+	sealed, err := e.seal(record)
+	if err != nil {
+		return err
+	}
+
+	if err := e.store.Save(ctx, sealed); err != nil {
+		return err
+	}
+
+	record.RefreshTokenHash = sealed.RefreshTokenHash
+	record.CreatedAt = sealed.CreatedAt
+	record.UpdatedAt = sealed.UpdatedAt
+
+	return nil
+}
+
+func (e envelopeTokenStore) Load(ctx context.Context, key OAuthTokenKey) (*OAuthTokenRecord, error) {
+	record, err := e.store.Load(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.open(record)
+}
+
+func (e envelopeTokenStore) Delete(ctx context.Context, key OAuthTokenKey) error {
+	return e.store.Delete(ctx, key)
+}
+
+func (e envelopeTokenStore) List(ctx context.Context, provider string, userID string) ([]*OAuthTokenRecord, error) {
+	records, err := e.store.List(ctx, provider, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, record := range records {
+		if records[idx], err = e.open(record); err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
+
+func (e envelopeTokenStore) FindByRefreshToken(ctx context.Context, provider string, refreshToken string) (*OAuthTokenRecord, error) {
+	record, err := e.store.FindByRefreshToken(ctx, provider, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.open(record)
+}
+
+// Reencrypt moves every record of the given providers onto the current key, plaintext
+// ones only with WithEnvelopePlaintext, and returns how many records were rewritten
+func (e envelopeTokenStore) Reencrypt(ctx context.Context, providers ...string) (int, error) {
+	count := 0
+
+	for _, provider := range providers {
+		records, err := e.store.List(ctx, provider, "")
+		if err != nil {
+			return count, err
+		}
+
+		for _, record := range records {
+			if err := ctx.Err(); err != nil {
+				return count, err
+			}
+
+			if !e.stale(record) {
+				continue
+			}
+
+			opened, err := e.open(record)
+			if err != nil {
+				return count, err
+			}
+
+			if err := e.Save(ctx, opened); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// EnvelopeTokenStore encrypts access, refresh and ID tokens before handing records to `store`
+func EnvelopeTokenStore(store TokenStore, keyring *oAuthKeyring, cbs ...OAuthEnvelopeCallback) *envelopeTokenStore {
+	envelope := &envelopeTokenStore{
+		store:   store,
+		keyring: keyring,
+	}
+
+	for _, cb := range cbs {
+		cb(envelope)
+	}
+
+	return envelope
+}
@@ -0,0 +1,205 @@
+package oauth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/feature"
+	"github.com/uptrace/bun/migrate"
+)
+
+const SQLTokenTable = "oauth_tokens"
+
+type sqlTokenModel struct {
+	bun.BaseModel `bun:"table:oauth_tokens,alias:ot"`
+
+	Provider         string    `bun:"provider,pk,type:varchar(64)"`
+	UserID           string    `bun:"user_id,pk,type:varchar(255)"`
+	Identity         string    `bun:"identity,pk,type:varchar(255)"`
+	Token            string    `bun:"token,notnull,type:text"`
+	RefreshTokenHash string    `bun:"refresh_token_hash,type:varchar(64)"`
+	CreatedAt        time.Time `bun:"created_at,notnull"`
+	UpdatedAt        time.Time `bun:"updated_at,notnull"`
+}
+
+func (m sqlTokenModel) record() (*OAuthTokenRecord, error) {
+	token := new(OAuthToken)
+	if err := json.Unmarshal([]byte(m.Token), token); err != nil {
+		return nil, OAuthErrorDecodeFailed()
+	}
+
+	return &OAuthTokenRecord{
+		OAuthTokenKey: OAuthTokenKey{
+			Provider: m.Provider,
+			UserID:   m.UserID,
+			Identity: m.Identity,
+		},
+		Token:            token,
+		RefreshTokenHash: m.RefreshTokenHash,
+		CreatedAt:        m.CreatedAt,
+		UpdatedAt:        m.UpdatedAt,
+	}, nil
+}
+
+// SQLTokenMigrations creates the table used by SQLTokenStore, register it with a
+// bun migrator on its own or merge it into the application's migrations
+var SQLTokenMigrations = migrate.NewMigrations()
+
+func init() {
+	SQLTokenMigrations.Add(migrate.Migration{
+		Name:    "20241120000000",
+		Comment: "create_oauth_tokens",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewCreateTable().Model((*sqlTokenModel)(nil)).IfNotExists().Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			_, err = db.NewCreateIndex().
+				Model((*sqlTokenModel)(nil)).
+				Index("oauth_tokens_refresh_token_hash_idx").
+				Column("provider", "refresh_token_hash").
+				IfNotExists().
+				Exec(ctx)
+			return err
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewDropTable().Model((*sqlTokenModel)(nil)).IfExists().Exec(ctx)
+			return err
+		},
+	})
+}
+
+type sqlTokenStore struct {
+	db bun.IDB
+}
+
+func (s sqlTokenStore) Save(ctx context.Context, record *OAuthTokenRecord) error {
+	record.prepare(time.Now())
+
+	token, err := json.Marshal(record.Token)
+	if err != nil {
+		return err
+	}
+
+	model := &sqlTokenModel{
+		Provider:         record.Provider,
+		UserID:           record.UserID,
+		Identity:         record.Identity,
+		Token:            string(token),
+		RefreshTokenHash: record.RefreshTokenHash,
+		CreatedAt:        record.CreatedAt,
+		UpdatedAt:        record.UpdatedAt,
+	}
+
+	query := s.db.NewInsert().Model(model)
+	switch {
+	case s.db.Dialect().Features().Has(feature.InsertOnConflict):
+		query = query.On("CONFLICT (provider, user_id, identity) DO UPDATE").
+			Set("token = EXCLUDED.token").
+			Set("refresh_token_hash = EXCLUDED.refresh_token_hash").
+			Set("updated_at = EXCLUDED.updated_at")
+	case s.db.Dialect().Features().Has(feature.InsertOnDuplicateKey):
+		query = query.On("DUPLICATE KEY UPDATE").
+			Set("token = VALUES(token)").
+			Set("refresh_token_hash = VALUES(refresh_token_hash)").
+			Set("updated_at = VALUES(updated_at)")
+	default:
+		return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			res, err := tx.NewUpdate().
+				Model(model).
+				Column("token", "refresh_token_hash", "updated_at").
+				WherePK().
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			if rows, err := res.RowsAffected(); err == nil && rows > 0 {
+				return nil
+			}
+
+			_, err = tx.NewInsert().Model(model).Exec(ctx)
+			return err
+		})
+	}
+
+	_, err = query.Exec(ctx)
+	return err
+}
+
+func (s sqlTokenStore) one(ctx context.Context, query *bun.SelectQuery) (*OAuthTokenRecord, error) {
+	model := new(sqlTokenModel)
+
+	err := query.Model(model).Limit(1).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, OAuthErrorTokenNotFound()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return model.record()
+}
+
+func (s sqlTokenStore) Load(ctx context.Context, key OAuthTokenKey) (*OAuthTokenRecord, error) {
+	return s.one(ctx, s.db.NewSelect().
+		Where("provider = ?", key.Provider).
+		Where("user_id = ?", key.UserID).
+		Where("identity = ?", key.Identity))
+}
+
+func (s sqlTokenStore) Delete(ctx context.Context, key OAuthTokenKey) error {
+	_, err := s.db.NewDelete().
+		Model((*sqlTokenModel)(nil)).
+		Where("provider = ?", key.Provider).
+		Where("user_id = ?", key.UserID).
+		Where("identity = ?", key.Identity).
+		Exec(ctx)
+
+	return err
+}
+
+func (s sqlTokenStore) List(ctx context.Context, provider string, userID string) ([]*OAuthTokenRecord, error) {
+	models := make([]sqlTokenModel, 0)
+
+	query := s.db.NewSelect().Model(&models).Where("provider = ?", provider)
+	if len(userID) > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.Order("user_id", "identity").Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	result := make([]*OAuthTokenRecord, 0, len(models))
+	for _, model := range models {
+		record, err := model.record()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, record)
+	}
+
+	return result, nil
+}
+
+func (s sqlTokenStore) FindByRefreshToken(ctx context.Context, provider string, refreshToken string) (*OAuthTokenRecord, error) {
+	hash := TokenHash(refreshToken)
+	if len(hash) < 1 {
+		return nil, OAuthErrorTokenNotFound()
+	}
+
+	return s.one(ctx, s.db.NewSelect().
+		Where("provider = ?", provider).
+		Where("refresh_token_hash = ?", hash))
+}
+
+// SQLTokenStore accepts a *bun.DB or a bun.Tx, run SQLTokenMigrations first
+func SQLTokenStore(db bun.IDB) *sqlTokenStore {
+	return &sqlTokenStore{db: db}
+}
@@ -0,0 +1,69 @@
+package oauth
+
+import (
+	"io"
+	"net/http"
+)
+
+type oAuthTransport struct {
+	provider OAuthServiceProvider
+	source   *oAuthTokenSource
+	base     http.RoundTripper
+}
+
+func (t *oAuthTransport) send(req *http.Request, token *OAuthToken) (*http.Response, error) {
+	clone := req.Clone(req.Context())
+	if err := t.provider.AuthorizeRequest(clone, token); err != nil {
+		return nil, err
+	}
+
+	return t.base.RoundTrip(clone)
+}
+
+func (t *oAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.source.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := t.send(req, token)
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+
+	// the body has been consumed already and can't be sent again
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return res, nil
+	}
+
+	fresh, err := t.source.Refresh(req.Context(), token)
+	if err != nil {
+		return res, nil
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return res, nil
+		}
+		retry.Body = body
+	}
+
+	io.Copy(io.Discard, io.LimitReader(res.Body, MaxErrorBodySize))
+	res.Body.Close()
+
+	return t.send(retry, fresh)
+}
+
+func Transport(provider OAuthServiceProvider, source *oAuthTokenSource, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &oAuthTransport{
+		provider: provider,
+		source:   source,
+		base:     base,
+	}
+}
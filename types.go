@@ -1,6 +1,10 @@
 package oauth
 
 import (
+	"context"
+	"net/http"
+	"time"
+
 	"github.com/DecxBase/core/types"
 	"github.com/DecxBase/core/utils"
 )
@@ -17,15 +21,23 @@ func (r OAuthRequestType) String() string {
 }
 
 type OAuthRequestResult struct {
-	Type OAuthRequestType
-	Data any
+	Type         OAuthRequestType
+	Data         any
+	State        string
+	Nonce        string
+	CodeVerifier string
 }
 
 type OAuthToken struct {
-	AccessToken string `json:"access_token"`
-	IDToken     string `json:"id_token"`
-	ExpiresIn   int64  `json:"expires_in"`
-	TokenType   string `json:"token_type"`
+	AccessToken  string         `json:"access_token"`
+	IDToken      string         `json:"id_token"`
+	RefreshToken string         `json:"refresh_token"`
+	TokenSecret  string         `json:"token_secret"`
+	ExpiresIn    int64          `json:"expires_in"`
+	TokenType    string         `json:"token_type"`
+	Scope        OAuthScopes    `json:"scope"`
+	Expiry       time.Time      `json:"expiry"`
+	Raw          map[string]any `json:"-"`
 }
 
 type OAuthUser struct {
@@ -34,6 +46,7 @@ type OAuthUser struct {
 	Identity     string
 	AccessToken  string
 	ExpiresIn    int64
+	Profile      types.JSONDumpData
 }
 
 type OAuthConfig interface {
@@ -44,14 +57,39 @@ type OAuthConfig interface {
 
 type OAuthRawCallback = func(*oAuthURI) *oAuthURI
 
+// OAuthPKCEResolver is implemented by providers which have to look their PKCE method up
+// first, the service prefers it over PKCEMethod
+type OAuthPKCEResolver interface {
+	PKCEMethodContext(context.Context) (string, error)
+}
+
+// OAuthStateKeyer is implemented by providers whose flow has no state parameter, such as
+// OAuth 1.0a. The service saves their state after Initialize, keyed by what StateKey reads
+// from the state data, and finds it on callback by reading the callback data the same way.
+type OAuthStateKeyer interface {
+	StateKey(map[string]string) string
+}
+
 type OAuthServiceProvider interface {
 	Name() string
+	PKCEMethod() string
 	FieldMappings() utils.DataMap[string]
 	Validate(types.JSONStringData) error
+	AuthorizeRequest(*http.Request, *OAuthToken) error
 	Initialize(*oAuthOptions, ...string) (*OAuthRequestResult, error)
+	InitializeContext(context.Context, *oAuthOptions, ...string) (*OAuthRequestResult, error)
 	Callback(*oAuthOptions) (*OAuthToken, error)
+	CallbackContext(context.Context, *oAuthOptions) (*OAuthToken, error)
 	RefreshToken(string) (*OAuthToken, error)
+	RefreshTokenContext(context.Context, string) (*OAuthToken, error)
 	TokenToUser(*OAuthToken) (*OAuthUser, error)
+	TokenToUserContext(context.Context, *OAuthToken) (*OAuthUser, error)
+	VerifyIDToken(string, string) (types.JSONDumpData, error)
+	VerifyIDTokenContext(context.Context, string, string) (types.JSONDumpData, error)
+	RevokeToken(string) error
+	RevokeTokenContext(context.Context, string) error
 	Get(string, []string, *oAuthOptions) (types.JSONDumpData, error)
+	GetContext(context.Context, string, []string, *oAuthOptions) (types.JSONDumpData, error)
 	Call(OAuthRawCallback, *oAuthRequestOptions) (types.JSONDumpData, error)
+	CallContext(context.Context, OAuthRawCallback, *oAuthRequestOptions) (types.JSONDumpData, error)
 }
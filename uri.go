@@ -2,7 +2,10 @@ package oauth
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/url"
+	"path"
+	"strings"
 )
 
 type oAuthURI struct {
@@ -27,6 +30,22 @@ func (u *oAuthURI) SetHost(host string) *oAuthURI {
 	return u
 }
 
+func (u *oAuthURI) SetURL(raw string) *oAuthURI {
+	parsed, err := ParseURI(raw)
+	if err != nil {
+		return u
+	}
+
+	u.Schema = parsed.Schema
+	u.Host = parsed.Host
+	u.Path = parsed.Path
+	for key, val := range parsed.Query {
+		u.Query[key] = val
+	}
+
+	return u
+}
+
 func (u *oAuthURI) SetPath(path string) *oAuthURI {
 	u.Path = path
 
@@ -59,6 +78,15 @@ func (u *oAuthURI) SetBody(key string, val string) *oAuthURI {
 	return u
 }
 
+func (u oAuthURI) GetFormPayload() []byte {
+	values := url.Values{}
+	for key, val := range u.Body {
+		values.Set(key, fmt.Sprintf("%v", val))
+	}
+
+	return []byte(values.Encode())
+}
+
 func (u oAuthURI) GetPayload() []byte {
 	bytes, err := json.Marshal(u.Body)
 
@@ -86,11 +114,16 @@ func (u *oAuthURI) generate() {
 }
 
 func (u oAuthURI) Clone() *oAuthURI {
+	query := make(map[string]string, len(u.Query))
+	for key, val := range u.Query {
+		query[key] = val
+	}
+
 	return &oAuthURI{
 		Schema: u.Schema,
 		Host:   u.Host,
 		Path:   u.Path,
-		Query:  u.Query,
+		Query:  query,
 		Body:   make(map[string]any),
 	}
 }
@@ -119,3 +152,47 @@ func URIHost(host string) *oAuthURI {
 		Body:   make(map[string]any),
 	}
 }
+
+func ParseURI(raw string) (*oAuthURI, error) {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	uri := URI(parsed.Host, strings.TrimPrefix(parsed.Path, "/"))
+	if len(parsed.Scheme) > 0 {
+		uri.SetSchema(parsed.Scheme)
+	}
+
+	for key := range parsed.Query() {
+		uri.Set(key, parsed.Query().Get(key))
+	}
+
+	return uri, nil
+}
+
+// URIBase accepts a full base URL, unlike URIHost it keeps the scheme, port and path
+// prefix so self-hosted instances on plain http or under a sub path work
+func URIBase(base string) (*oAuthURI, error) {
+	if !strings.Contains(base, "://") {
+		base = "https://" + base
+	}
+
+	uri, err := ParseURI(strings.TrimRight(base, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(uri.Host) < 1 {
+		return nil, fmt.Errorf("oauth: base URL %q has no host", base)
+	}
+
+	return uri, nil
+}
+
+// Join appends `to` to the current path
+func (u *oAuthURI) Join(to string) *oAuthURI {
+	u.Path = strings.Trim(path.Join(u.Path, to), "/")
+
+	return u
+}